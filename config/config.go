@@ -1,8 +1,10 @@
 package config
 
 import (
+	"os"
 	"time"
 
+	"github.com/docker/go-units"
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/viper"
 
@@ -23,7 +25,36 @@ func init() {
 	v.SetDefault("listen_port", 3000)
 	v.SetDefault("external_schema", "http")
 	v.SetDefault("external_host", "localhost")
+	v.SetDefault("external_port", 0)
 	v.SetDefault("registry.image", "gosgradio/gradio")
+	v.SetDefault("images", []interface{}{})
+	v.SetDefault("pull.retries", 0)
+	v.SetDefault("pull.backoff", "5s")
+	v.SetDefault("pull.max_backoff", "5m")
+	v.SetDefault("vnc.publish_ports", false)
+	v.SetDefault("vnc.network", "")
+	v.SetDefault("jwt.timeout", "1h")
+	v.SetDefault("jwt.max_refresh", "720h")
+	v.SetDefault("workspace.path", "/root")
+	v.SetDefault("workspace.root", "")
+	v.SetDefault("workspace.uid", 0)
+	v.SetDefault("workspace.gid", 0)
+	v.SetDefault("files.max_upload_size", "20m")
+	v.SetDefault("files.max_download_size", "100m")
+	v.SetDefault("limits.cpus", 1)
+	v.SetDefault("limits.memory", "2g")
+	v.SetDefault("limits.pids", 1024)
+	v.SetDefault("limits.disk", "")
+	v.SetDefault("class_limits", map[string]interface{}{})
+	v.SetDefault("grades.min", 2)
+	v.SetDefault("grades.max", 5)
+	v.SetDefault("ports.min", 5899)
+	v.SetDefault("ports.max", 6000)
+	v.SetDefault("sessions.idle_timeout", "30m")
+	v.SetDefault("sessions.max_lifetime", "4h")
+	v.SetDefault("sessions.reap_interval", "1m")
+	v.SetDefault("sessions.reconcile_interval", "10m")
+	v.SetDefault("sessions.restart_stopped", false)
 
 	log.SetFormatter(&log.TextFormatter{
 		ForceColors:     true,
@@ -61,13 +92,125 @@ type Config struct {
 		User     string `mapstructure:"user" validate:"omitempty"`
 		Password string `mapstructure:"password" validate:"omitempty"`
 	} `mapstructure:"registry" validate:"required,dive"`
+	Images []ImageProfile `mapstructure:"images" validate:"dive"`
+	Pull   struct {
+		Retries    int           `mapstructure:"retries" validate:"gte=0"`
+		Backoff    time.Duration `mapstructure:"backoff" validate:"required,gt=0"`
+		MaxBackoff time.Duration `mapstructure:"max_backoff" validate:"required,gtefield=Backoff"`
+	} `mapstructure:"pull" validate:"required,dive"`
+	VNC struct {
+		PublishPorts bool   `mapstructure:"publish_ports"`
+		Network      string `mapstructure:"network" validate:"omitempty"`
+		Secret       string `mapstructure:"secret" validate:"required"`
+	} `mapstructure:"vnc" validate:"required,dive"`
+	JWT struct {
+		Secret     string        `mapstructure:"secret" validate:"required"`
+		Timeout    time.Duration `mapstructure:"timeout" validate:"required,gt=0"`
+		MaxRefresh time.Duration `mapstructure:"max_refresh" validate:"gte=0"`
+	} `mapstructure:"jwt" validate:"required,dive"`
+	Grades struct {
+		Min int `mapstructure:"min" validate:"numeric"`
+		Max int `mapstructure:"max" validate:"numeric,gtfield=Min"`
+	} `mapstructure:"grades" validate:"required,dive"`
+	Ports struct {
+		Min int `mapstructure:"min" validate:"required,numeric,gte=1,lte=65535"`
+		Max int `mapstructure:"max" validate:"required,numeric,gtefield=Min,lte=65535"`
+	} `mapstructure:"ports" validate:"required,dive"`
+	// Секция не называется home, иначе переменная окружения HOME скрывает ее ключи
+	Workspace struct {
+		Path string `mapstructure:"path" validate:"required,startswith=/"`
+		Root string `mapstructure:"root" validate:"omitempty,startswith=/"`
+		UID  int    `mapstructure:"uid" validate:"gte=0"`
+		GID  int    `mapstructure:"gid" validate:"gte=0"`
+	} `mapstructure:"workspace" validate:"required,dive"`
+	Files struct {
+		MaxUploadSize   string `mapstructure:"max_upload_size" validate:"required"`
+		MaxDownloadSize string `mapstructure:"max_download_size" validate:"required"`
+	} `mapstructure:"files" validate:"required,dive"`
+	Limits      Limits            `mapstructure:"limits" validate:"required"`
+	ClassLimits map[string]Limits `mapstructure:"class_limits" validate:"dive"`
+	Sessions    struct {
+		IdleTimeout       time.Duration `mapstructure:"idle_timeout" validate:"gte=0"`
+		MaxLifetime       time.Duration `mapstructure:"max_lifetime" validate:"gte=0"`
+		ReapInterval      time.Duration `mapstructure:"reap_interval" validate:"required,gt=0"`
+		ReconcileInterval time.Duration `mapstructure:"reconcile_interval" validate:"required,gt=0"`
+		RestartStopped    bool          `mapstructure:"restart_stopped"`
+	} `mapstructure:"sessions" validate:"required,dive"`
 	ExternalHost   string `mapstructure:"external_host" validate:"required,hostname"`
 	ExternalSchema string `mapstructure:"external_schema" validate:"required,oneof=http https"`
+	ExternalPort   int    `mapstructure:"external_port" validate:"gte=0,lte=65535"`
+}
+
+// Limits is a container resource limits of session, zero values mean unlimited
+type Limits struct {
+	CPUs   float64 `mapstructure:"cpus" validate:"gte=0"`
+	Memory string  `mapstructure:"memory" validate:"omitempty"` // 512m, 2g
+	Pids   int64   `mapstructure:"pids" validate:"gte=0"`
+	Disk   string  `mapstructure:"disk" validate:"omitempty"` // 10G, поддерживается не всеми storage драйверами
+}
+
+// ImageProfile is a named container image of lab desktop, registry is the
+// "default" profile unless it's redefined here
+type ImageProfile struct {
+	Name     string   `mapstructure:"name" validate:"required,max=64"`
+	Image    string   `mapstructure:"image" validate:"required"`
+	User     string   `mapstructure:"user" validate:"omitempty"`
+	Password string   `mapstructure:"password" validate:"omitempty"`
+	VNCPort  int      `mapstructure:"vnc_port" validate:"omitempty,gte=1,lte=65535"`
+	Env      []string `mapstructure:"env" validate:"omitempty"`
+	Limits   Limits   `mapstructure:"limits"`
+}
+
+// Переменные окружения секретов, они не привязываются к viper, иначе попадут
+// в записываемый при первом запуске gradio.yml
+const (
+	JWTSecretEnv = "JWT_SECRET"
+	VNCSecretEnv = "VNC_SECRET"
+)
+
+// Secret returns value of secret key from config, env variable is used if it's not set
+func Secret(key, env string) string {
+	if secret := v.GetString(key); secret != "" {
+		return secret
+	}
+	return os.Getenv(env)
 }
 
 // Validate base check config variables
 func (c *Config) Validate() error {
-	return validator.New().Struct(c)
+	// Секреты не имеют значений по умолчанию, без них gradio не запустится
+	if c.JWT.Secret == "" {
+		c.JWT.Secret = os.Getenv(JWTSecretEnv)
+	}
+	if c.VNC.Secret == "" {
+		c.VNC.Secret = os.Getenv(VNCSecretEnv)
+	}
+	if err := validator.New().Struct(c); err != nil {
+		return err
+	}
+
+	limits := []Limits{c.Limits}
+	for _, l := range c.ClassLimits {
+		limits = append(limits, l)
+	}
+	for _, p := range c.Images {
+		limits = append(limits, p.Limits)
+	}
+	for _, l := range limits {
+		if l.Memory == "" {
+			continue
+		}
+		if _, err := units.RAMInBytes(l.Memory); err != nil {
+			return err
+		}
+	}
+
+	for _, size := range []string{c.Files.MaxUploadSize, c.Files.MaxDownloadSize} {
+		if _, err := units.RAMInBytes(size); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Unmarshal unmarshal config
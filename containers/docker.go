@@ -0,0 +1,231 @@
+package containers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+)
+
+// Docker is a Runtime implementation on top of docker engine API
+type Docker struct {
+	cli *client.Client
+}
+
+// NewDocker creates docker runtime configured from environment
+func NewDocker() (*Docker, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+	return &Docker{cli: cli}, nil
+}
+
+// Close closes docker client connections
+func (d *Docker) Close() error {
+	return d.cli.Close()
+}
+
+func (d *Docker) Pull(ctx context.Context, ref string, auth Auth) (io.ReadCloser, error) {
+	encodedJSON, err := json.Marshal(types.AuthConfig{
+		Username: auth.Username,
+		Password: auth.Password,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := d.cli.ImagePull(ctx, ref, types.ImagePullOptions{
+		RegistryAuth: base64.URLEncoding.EncodeToString(encodedJSON),
+	})
+	return out, wrapErr(err)
+}
+
+func (d *Docker) HasImage(ctx context.Context, ref string) (bool, error) {
+	_, _, err := d.cli.ImageInspectWithRaw(ctx, ref)
+	if client.IsErrNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, wrapErr(err)
+	}
+	return true, nil
+}
+
+func (d *Docker) Create(ctx context.Context, spec Spec) (string, error) {
+	var portSpecs []string
+	for hostPort, containerPort := range spec.Ports {
+		portSpecs = append(portSpecs, strconv.Itoa(hostPort)+":"+strconv.Itoa(containerPort))
+	}
+
+	exposedPorts, portBindings, err := nat.ParsePortSpecs(portSpecs)
+	if err != nil {
+		return "", err
+	}
+
+	hostConfig := &container.HostConfig{
+		PortBindings: portBindings,
+		NetworkMode:  container.NetworkMode(spec.Network),
+		Resources: container.Resources{
+			NanoCPUs: spec.Resources.NanoCPUs,
+			Memory:   spec.Resources.Memory,
+		},
+	}
+	for _, m := range spec.Mounts {
+		hostConfig.Binds = append(hostConfig.Binds, m.Source+":"+m.Target)
+	}
+	if spec.Resources.Pids != 0 {
+		hostConfig.Resources.PidsLimit = &spec.Resources.Pids
+	}
+	if spec.Resources.DiskSize != "" {
+		hostConfig.StorageOpt = map[string]string{"size": spec.Resources.DiskSize}
+	}
+
+	resp, err := d.cli.ContainerCreate(ctx, &container.Config{
+		Image:        spec.Image,
+		Env:          spec.Env,
+		Labels:       spec.Labels,
+		ExposedPorts: exposedPorts,
+	}, hostConfig, nil, nil, "")
+	if err != nil {
+		return "", wrapErr(err)
+	}
+
+	return resp.ID, nil
+}
+
+func (d *Docker) Start(ctx context.Context, id string) error {
+	return wrapErr(d.cli.ContainerStart(ctx, id, types.ContainerStartOptions{}))
+}
+
+func (d *Docker) Stop(ctx context.Context, id string) error {
+	return wrapErr(d.cli.ContainerStop(ctx, id, nil))
+}
+
+func (d *Docker) Remove(ctx context.Context, id string) error {
+	return wrapErr(d.cli.ContainerRemove(ctx, id, types.ContainerRemoveOptions{RemoveVolumes: true}))
+}
+
+func (d *Docker) Inspect(ctx context.Context, id string) (Info, error) {
+	resp, err := d.cli.ContainerInspect(ctx, id)
+	if err != nil {
+		return Info{}, wrapErr(err)
+	}
+
+	info := Info{ID: resp.ID, Image: resp.Config.Image, Labels: resp.Config.Labels}
+	if resp.State != nil {
+		info.Running = resp.State.Running
+	}
+	info.Created, _ = time.Parse(time.RFC3339Nano, resp.Created)
+	if resp.NetworkSettings != nil {
+		info.IP = resp.NetworkSettings.IPAddress
+		for _, network := range resp.NetworkSettings.Networks {
+			if info.IP == "" && network != nil {
+				info.IP = network.IPAddress
+			}
+		}
+	}
+	return info, nil
+}
+
+func (d *Docker) Exec(ctx context.Context, id string, cmd []string) ([]byte, error) {
+	exec, err := d.cli.ContainerExecCreate(ctx, id, types.ExecConfig{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+	})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+
+	resp, err := d.cli.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	defer resp.Close()
+
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, resp.Reader); err != nil {
+		return nil, err
+	}
+
+	inspect, err := d.cli.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	if inspect.ExitCode != 0 {
+		return stdout.Bytes(), fmt.Errorf("exec %v exited with code %d: %s", cmd, inspect.ExitCode, stderr.String())
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func (d *Docker) List(ctx context.Context, labels map[string]string) ([]Info, error) {
+	args := filters.NewArgs()
+	for key, value := range labels {
+		args.Add("label", key+"="+value)
+	}
+
+	list, err := d.cli.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: args})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+
+	infos := make([]Info, 0, len(list))
+	for _, c := range list {
+		infos = append(infos, Info{
+			ID:      c.ID,
+			Image:   c.Image,
+			Labels:  c.Labels,
+			Running: c.State == "running",
+			Created: time.Unix(c.Created, 0),
+		})
+	}
+	return infos, nil
+}
+
+func (d *Docker) CopyTo(ctx context.Context, id, dir string, content io.Reader) error {
+	return wrapErr(d.cli.CopyToContainer(ctx, id, dir, content, types.CopyToContainerOptions{}))
+}
+
+func (d *Docker) CopyFrom(ctx context.Context, id, path string) (io.ReadCloser, PathStat, error) {
+	out, stat, err := d.cli.CopyFromContainer(ctx, id, path)
+	if err != nil {
+		return nil, PathStat{}, wrapErr(err)
+	}
+	return out, PathStat{Name: stat.Name, Size: stat.Size, Mode: stat.Mode, Mtime: stat.Mtime}, nil
+}
+
+func (d *Docker) CreateVolume(ctx context.Context, name string, labels map[string]string) error {
+	_, err := d.cli.VolumeCreate(ctx, volume.VolumeCreateBody{Name: name, Labels: labels})
+	return wrapErr(err)
+}
+
+func (d *Docker) RemoveVolume(ctx context.Context, name string) error {
+	return wrapErr(d.cli.VolumeRemove(ctx, name, false))
+}
+
+// wrapErr converts docker client errors into runtime errors
+func wrapErr(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case client.IsErrNotFound(err):
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	case client.IsErrConnectionFailed(err):
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,257 @@
+package containers
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fake is an in-memory Runtime for tests, it doesn't run anything
+type Fake struct {
+	mu         sync.Mutex
+	seq        int
+	containers map[string]*Info
+	// files is a container file system: container ID -> path -> content, nil content for directories
+	files map[string]map[string][]byte
+	// Volumes contains created volume names
+	Volumes map[string]bool
+	// Images contains pulled image references
+	Images map[string]bool
+	// Err is returned from every call when set, e.g. ErrUnavailable
+	Err error
+	// ExecHandler answers Exec calls, by default Exec returns empty output
+	ExecHandler func(id string, cmd []string) ([]byte, error)
+}
+
+// NewFake creates an empty in-memory runtime
+func NewFake() *Fake {
+	return &Fake{
+		containers: map[string]*Info{},
+		Volumes:    map[string]bool{},
+		files:      map[string]map[string][]byte{},
+		Images:     map[string]bool{},
+	}
+}
+
+func (f *Fake) Pull(ctx context.Context, ref string, auth Auth) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	f.Images[ref] = true
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+func (f *Fake) HasImage(ctx context.Context, ref string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return false, f.Err
+	}
+	return f.Images[ref], nil
+}
+
+func (f *Fake) Create(ctx context.Context, spec Spec) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return "", f.Err
+	}
+	f.seq++
+	id := fmt.Sprintf("fake%060d", f.seq)
+	labels := map[string]string{}
+	for key, value := range spec.Labels {
+		labels[key] = value
+	}
+	f.containers[id] = &Info{ID: id, Image: spec.Image, Labels: labels, Created: time.Now(), IP: "127.0.0.1"}
+	return id, nil
+}
+
+func (f *Fake) Start(ctx context.Context, id string) error {
+	return f.setRunning(id, true)
+}
+
+func (f *Fake) Stop(ctx context.Context, id string) error {
+	return f.setRunning(id, false)
+}
+
+func (f *Fake) Remove(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	if _, ok := f.containers[id]; !ok {
+		return ErrNotFound
+	}
+	delete(f.containers, id)
+	return nil
+}
+
+func (f *Fake) Inspect(ctx context.Context, id string) (Info, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return Info{}, f.Err
+	}
+	c, ok := f.containers[id]
+	if !ok {
+		return Info{}, ErrNotFound
+	}
+	return *c, nil
+}
+
+func (f *Fake) Exec(ctx context.Context, id string, cmd []string) ([]byte, error) {
+	if _, err := f.Inspect(ctx, id); err != nil {
+		return nil, err
+	}
+	if f.ExecHandler == nil {
+		return nil, nil
+	}
+	return f.ExecHandler(id, cmd)
+}
+
+func (f *Fake) List(ctx context.Context, labels map[string]string) ([]Info, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	var infos []Info
+next:
+	for _, c := range f.containers {
+		for key, value := range labels {
+			if c.Labels[key] != value {
+				continue next
+			}
+		}
+		infos = append(infos, *c)
+	}
+	return infos, nil
+}
+
+func (f *Fake) CopyTo(ctx context.Context, id, dir string, content io.Reader) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	if _, ok := f.containers[id]; !ok {
+		return ErrNotFound
+	}
+	if f.files[id] == nil {
+		f.files[id] = map[string][]byte{}
+	}
+
+	tr := tar.NewReader(content)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		name := path.Join(dir, hdr.Name)
+		if hdr.Typeflag == tar.TypeDir {
+			f.files[id][name] = nil
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		f.files[id][name] = data
+	}
+}
+
+func (f *Fake) CopyFrom(ctx context.Context, id, src string) (io.ReadCloser, PathStat, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, PathStat{}, f.Err
+	}
+	if _, ok := f.containers[id]; !ok {
+		return nil, PathStat{}, ErrNotFound
+	}
+
+	var (
+		buf   bytes.Buffer
+		tw    = tar.NewWriter(&buf)
+		stat  = PathStat{Name: path.Base(src), Mode: os.ModeDir | 0755}
+		found bool
+	)
+	src = path.Clean(src)
+	for name, data := range f.files[id] {
+		if name != src && !strings.HasPrefix(name, src+"/") {
+			continue
+		}
+		found = true
+
+		hdr := &tar.Header{Name: path.Join(stat.Name, strings.TrimPrefix(name, src)), Mode: 0644, Size: int64(len(data))}
+		if data == nil {
+			hdr.Typeflag, hdr.Mode, hdr.Name = tar.TypeDir, 0755, hdr.Name+"/"
+		}
+		if name == src && data != nil {
+			stat.Size, stat.Mode = int64(len(data)), 0644
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, PathStat{}, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, PathStat{}, err
+		}
+	}
+	if !found {
+		return nil, PathStat{}, ErrNotFound
+	}
+	if err := tw.Close(); err != nil {
+		return nil, PathStat{}, err
+	}
+	return io.NopCloser(&buf), stat, nil
+}
+
+func (f *Fake) CreateVolume(ctx context.Context, name string, labels map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	f.Volumes[name] = true
+	return nil
+}
+
+func (f *Fake) RemoveVolume(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	if !f.Volumes[name] {
+		return ErrNotFound
+	}
+	delete(f.Volumes, name)
+	return nil
+}
+
+func (f *Fake) setRunning(id string, running bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	c, ok := f.containers[id]
+	if !ok {
+		return ErrNotFound
+	}
+	c.Running = running
+	return nil
+}
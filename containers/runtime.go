@@ -0,0 +1,96 @@
+package containers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when container does not exist in runtime
+	ErrNotFound = errors.New("container not found")
+	// ErrUnavailable is returned when container runtime can't be reached
+	ErrUnavailable = errors.New("container runtime is unavailable")
+)
+
+// Runtime is a container engine that runs student lab desktops
+type Runtime interface {
+	// Pull downloads image from registry, returned reader streams pull progress
+	Pull(ctx context.Context, ref string, auth Auth) (io.ReadCloser, error)
+	// HasImage reports whether image is present locally
+	HasImage(ctx context.Context, ref string) (bool, error)
+	// Create creates a new container from spec and returns its ID
+	Create(ctx context.Context, spec Spec) (string, error)
+	Start(ctx context.Context, id string) error
+	Stop(ctx context.Context, id string) error
+	Remove(ctx context.Context, id string) error
+	Inspect(ctx context.Context, id string) (Info, error)
+	// Exec runs command inside running container and returns its stdout
+	Exec(ctx context.Context, id string, cmd []string) ([]byte, error)
+	// List returns all containers (running or not) that have every given label
+	List(ctx context.Context, labels map[string]string) ([]Info, error)
+	// CopyTo extracts tar archive into existing directory of container
+	CopyTo(ctx context.Context, id, dir string, content io.Reader) error
+	// CopyFrom returns tar archive of container file or directory
+	CopyFrom(ctx context.Context, id, path string) (io.ReadCloser, PathStat, error)
+	// CreateVolume creates named volume, existing volume is not an error
+	CreateVolume(ctx context.Context, name string, labels map[string]string) error
+	RemoveVolume(ctx context.Context, name string) error
+}
+
+// Auth is a registry credentials
+type Auth struct {
+	Username string
+	Password string
+}
+
+// Spec describes container that should be created
+type Spec struct {
+	Image  string
+	Env    []string
+	Labels map[string]string
+	// Ports maps host ports to container ports
+	Ports map[int]int
+	// Network is a docker network to attach container to, default network if empty
+	Network   string
+	Resources Resources
+	Mounts    []Mount
+}
+
+// Mount is a volume or host directory mounted into container
+type Mount struct {
+	// Source is a volume name or an absolute host path
+	Source string
+	Target string
+}
+
+// Resources is a container limits, zero value means unlimited
+type Resources struct {
+	NanoCPUs int64
+	// Memory in bytes
+	Memory int64
+	Pids   int64
+	// DiskSize is a writable layer size, e.g. 10G, supported not by every storage driver
+	DiskSize string
+}
+
+// Info is a container state reported by runtime
+type Info struct {
+	ID      string
+	Image   string
+	Labels  map[string]string
+	Running bool
+	Created time.Time
+	// IP is a container address reachable from gradio
+	IP string
+}
+
+// PathStat describes a path inside container
+type PathStat struct {
+	Name  string
+	Size  int64
+	Mode  os.FileMode
+	Mtime time.Time
+}
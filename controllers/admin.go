@@ -1,17 +1,24 @@
 package controllers
 
 import (
+	"errors"
+	"gradio/containers"
 	"gradio/models"
+	"gradio/supervisor"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type AddUserResponse struct {
 	ID        string `json:"id"`
+	Login     string `json:"login"`
 	GivenName string `json:"given_name"`
 	Surname   string `json:"surname"`
-	Class     string `json:"class"`
+	ClassID   string `json:"class_id,omitempty"`
+	Class     string `json:"class,omitempty"`
 	Password  string `json:"password,omitempty"`
 	Rights    string `json:"rights"`
 }
@@ -22,8 +29,11 @@ func AddUser(c *gin.Context) {
 		data struct {
 			GivenName string `json:"given_name" binding:"required"`
 			Surname   string `json:"surname" binding:"required"`
-			Class     string `json:"class" binding:"required"`
-			Password  string `json:"password" binding:"omitempty"`
+			Class     string `json:"class" binding:"omitempty"`
+			ClassID   string `json:"class_id" binding:"omitempty,uuid"`
+			Login     string `json:"login" binding:"omitempty,ulogin"`
+			Password  string `json:"password" binding:"omitempty,upwd"`
+			Rights    string `json:"rights" binding:"omitempty,oneof=admin teacher student"`
 		}
 		user models.User
 	)
@@ -33,37 +43,78 @@ func AddUser(c *gin.Context) {
 		return
 	}
 
-	if db.First(&user, "surname = ? AND class = ?", data.Surname, data.Class).RowsAffected != 0 {
+	if data.Rights == "" {
+		data.Rights = models.RightsStudent
+	}
+
+	class, ok := resolveClass(c, data.ClassID, data.Class)
+	if !ok {
+		return
+	}
+	if class == nil && data.Rights == models.RightsStudent {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "student must have a class"})
+		return
+	}
+	if class != nil {
+		user.ClassID, user.Class = &class.ID, class
+	}
+
+	if !canManage(c, &models.User{Rights: data.Rights, ClassID: user.ClassID}) {
+		return
+	}
+
+	if db.Where("class_id IS NOT DISTINCT FROM ?", user.ClassID).First(&models.User{}, "surname = ?", data.Surname).RowsAffected != 0 {
 		c.JSON(http.StatusConflict, gin.H{"error": "user with this class and surname already exist"})
 		return
 	}
 
+	if data.Login != "" {
+		if taken, err := models.LoginTaken(db, data.Login, ""); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "can't check user login"})
+			return
+		} else if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": "user with this login already exist"})
+			return
+		}
+	}
+
 	user.GivenName = &data.GivenName
 	user.Surname = data.Surname
-	user.Class = data.Class
+	user.Rights = data.Rights
+	user.Login = data.Login
+	if user.Login == "" {
+		if err := user.GenLogin(db); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "can't generate user login"})
+			return
+		}
+	}
 	if err := user.GenHash(data.Password); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't create user hash from password"})
 		return
 	}
 
-	if err := db.Create(&user).Error; err != nil {
+	if err := db.Omit("Class").Create(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't create user in database"})
 		return
 	}
 
 	response := AddUserResponse{
 		ID:        user.ID,
+		Login:     user.Login,
 		GivenName: *user.GivenName,
 		Surname:   user.Surname,
-		Class:     user.Class,
+		Class:     user.ClassName(),
 		Password:  user.Password,
 		Rights:    user.Rights,
 	}
+	if user.ClassID != nil {
+		response.ClassID = *user.ClassID
+	}
 
 	c.JSON(http.StatusOK, gin.H{"user": response})
 }
 
-func DelStudent(c *gin.Context) {
+func GetUser(c *gin.Context) {
 	var (
 		db   = models.GetDB()
 		user models.User
@@ -77,27 +128,212 @@ func DelStudent(c *gin.Context) {
 		return
 	}
 
-	if db.First(&user, "id = ?", data.ID).RowsAffected == 0 {
+	if db.Preload("Session").Preload("Class").Preload("Grades", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("created_at")
+	}).First(&user, "id = ?", data.ID).RowsAffected == 0 {
 		c.JSON(http.StatusConflict, gin.H{"error": "user with this id not found"})
 		return
 	}
 
-	if err := db.Model(&user).Association("Session").Clear(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error on clear user session"})
+	if !canManage(c, &user) {
 		return
 	}
 
-	if err := db.Delete(&user); err != nil {
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// UpdateUser partially updates user, password is reset to a generated one with reset_password
+func UpdateUser(c *gin.Context) {
+	var (
+		db   = models.GetDB()
+		user models.User
+		uri  struct {
+			ID string `uri:"id" binding:"required,uuid"`
+		}
+		data struct {
+			GivenName     *string `json:"given_name" binding:"omitempty,max=128"`
+			Surname       *string `json:"surname" binding:"omitempty,min=1,max=128"`
+			Class         *string `json:"class" binding:"omitempty"`
+			ClassID       *string `json:"class_id" binding:"omitempty,uuid"`
+			Login         *string `json:"login" binding:"omitempty,ulogin"`
+			Rights        *string `json:"rights" binding:"omitempty,oneof=admin teacher student"`
+			Password      *string `json:"password" binding:"omitempty,upwd"`
+			ResetPassword bool    `json:"reset_password"`
+		}
+	)
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.Preload("Class").First(&user, "id = ?", uri.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "user with this id not found"})
+		return
+	}
+
+	if !canManage(c, &user) {
+		return
+	}
+
+	if data.GivenName != nil {
+		user.GivenName = data.GivenName
+	}
+	if data.Surname != nil {
+		user.Surname = *data.Surname
+	}
+	if data.Rights != nil {
+		if manager := currentUser(c); *data.Rights != user.Rights && !manager.Can(models.PermStaff) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "not enough rights to change user role"})
+			return
+		}
+		user.Rights = *data.Rights
+	}
+	if data.Class != nil || data.ClassID != nil {
+		var id, name string
+		if data.ClassID != nil {
+			id = *data.ClassID
+		}
+		if data.Class != nil {
+			name = *data.Class
+		}
+		class, ok := resolveClass(c, id, name)
+		if !ok {
+			return
+		}
+		user.Class, user.ClassID = class, nil
+		if class != nil {
+			user.ClassID = &class.ID
+		}
+	}
+	if user.ClassID == nil && user.Rights == models.RightsStudent {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "student must have a class"})
+		return
+	}
+
+	// Учитель не может перевести студента в чужой класс
+	if !canManage(c, &user) {
+		return
+	}
+
+	if db.Where("class_id IS NOT DISTINCT FROM ? AND id <> ?", user.ClassID, user.ID).First(&models.User{}, "surname = ?", user.Surname).RowsAffected != 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "user with this class and surname already exist"})
+		return
+	}
+
+	if data.Login != nil {
+		if taken, err := models.LoginTaken(db, *data.Login, user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "can't check user login"})
+			return
+		} else if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": "user with this login already exist"})
+			return
+		}
+		user.Login = *data.Login
+	}
+
+	if data.Password != nil || data.ResetPassword {
+		pass := ""
+		if data.Password != nil {
+			pass = *data.Password
+		}
+		if err := user.GenHash(pass); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "can't create user hash from password"})
+			return
+		}
+	}
+
+	if err := db.Omit("Class").Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't update user in database"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *Handlers) DelStudent(c *gin.Context) {
+	var (
+		db   = models.GetDB()
+		user models.User
+		data struct {
+			ID string `uri:"id" binding:"required,uuid"`
+		}
+	)
+
+	if err := c.ShouldBindUri(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.Preload("Session").First(&user, "id = ?", data.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "user with this id not found"})
+		return
+	}
+
+	if !canManage(c, &user) {
+		return
+	}
+
+	reclaimed, ok := h.closeUserSession(c, &user)
+	if !ok {
+		return
+	}
+
+	err := supervisor.RemoveHome(c, h.rt, &user)
+	switch {
+	case errors.Is(err, containers.ErrUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "container runtime is unreachable, user home is kept"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error on remove user home"})
+		return
+	}
+
+	if err := db.Delete(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "error on delete user"})
 		return
 	}
 
-	// TODO: Добавить удаление контейнера сессии
+	c.JSON(http.StatusOK, gin.H{"container_reclaimed": reclaimed})
+}
+
+func (h *Handlers) CloseSession(c *gin.Context) {
+	var (
+		db   = models.GetDB()
+		user models.User
+		data struct {
+			ID string `uri:"id" binding:"required,uuid"`
+		}
+	)
+
+	if err := c.ShouldBindUri(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.Preload("Session").First(&user, "id = ?", data.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "user with this id not found"})
+		return
+	}
+
+	if !canManage(c, &user) {
+		return
+	}
+
+	reclaimed, ok := h.closeUserSession(c, &user)
+	if !ok {
+		return
+	}
 
-	c.Status(http.StatusOK)
+	c.JSON(http.StatusOK, gin.H{"container_reclaimed": reclaimed})
 }
 
-func CloseSession(c *gin.Context) {
+func (h *Handlers) RotateSessionPassword(c *gin.Context) {
 	var (
 		db   = models.GetDB()
 		user models.User
@@ -111,27 +347,190 @@ func CloseSession(c *gin.Context) {
 		return
 	}
 
-	if db.First(&user, "id = ?", data.ID).RowsAffected == 0 {
+	if db.Preload("Session").First(&user, "id = ?", data.ID).RowsAffected == 0 {
 		c.JSON(http.StatusConflict, gin.H{"error": "user with this id not found"})
 		return
 	}
 
-	if err := db.Model(&user).Association("Session").Clear(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error on clear user session"})
+	if !canManage(c, &user) {
+		return
+	}
+
+	if user.Session == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "user has no session"})
+		return
+	}
+
+	_, err := supervisor.RotatePassword(c, h.rt, user.Session)
+	switch {
+	case errors.Is(err, containers.ErrUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "container runtime is unreachable"})
+		return
+	case errors.Is(err, containers.ErrNotFound):
+		c.JSON(http.StatusConflict, gin.H{"error": "session container not found"})
 		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't rotate session password"})
+		return
+	}
+
+	// Новый пароль видит только владелец сессии в ответе POST /session
+	c.Status(http.StatusNoContent)
+}
+
+// closeUserSession closes the user session if there is one and writes an
+// error response on failure. reclaimed reports whether a container was removed.
+func (h *Handlers) closeUserSession(c *gin.Context, user *models.User) (reclaimed bool, ok bool) {
+	if user.Session == nil {
+		return false, true
 	}
 
-	// TODO: Добавить удаление контейнера сессии
+	reclaimed, err := supervisor.Close(c, h.rt, user.Session, models.CloseByAdmin)
+	switch {
+	case errors.Is(err, containers.ErrUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "container runtime is unreachable, session is kept"})
+		return false, false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error on close user session"})
+		return false, false
+	}
 
-	c.Status(http.StatusOK)
+	return reclaimed, true
 }
 
-func GetUsers(c *gin.Context) {
+// usersSorts maps sort parameter of users list to order column
+var usersSorts = map[string]string{
+	"surname":    "users.surname",
+	"login":      "users.login",
+	"rights":     "users.rights",
+	"class":      "classes.name",
+	"created_at": "users.created_at",
+}
+
+// GetUsers returns page of users filtered by class, rights, session status and surname
+func (h *Handlers) GetUsers(c *gin.Context) {
 	var (
 		db    = models.GetDB()
 		users []models.User
+		total int64
+		query struct {
+			Page    int    `form:"page" binding:"omitempty,gte=1"`
+			PerPage int    `form:"per_page" binding:"omitempty,gte=1,lte=200"`
+			ClassID string `form:"class_id" binding:"omitempty,uuid"`
+			Rights  string `form:"rights" binding:"omitempty,oneof=admin teacher student"`
+			Session string `form:"session" binding:"omitempty,oneof=none online offline"`
+			Search  string `form:"q" binding:"omitempty,max=128"`
+			Sort    string `form:"sort" binding:"omitempty,oneof=surname login rights class created_at"`
+			Order   string `form:"order" binding:"omitempty,oneof=asc desc"`
+		}
 	)
 
-	db.Preload("Session").Find(&users)
-	c.JSON(http.StatusOK, gin.H{"users": users})
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.Page == 0 {
+		query.Page = 1
+	}
+	if query.PerPage == 0 {
+		query.PerPage = 50
+	}
+	if query.Sort == "" {
+		query.Sort = "surname"
+	}
+	if query.Order == "" {
+		query.Order = "asc"
+	}
+
+	// Без среды контейнеров статусы сессий не показываются, фильтр online/offline невозможен
+	running, err := supervisor.RunningContainers(c, h.rt)
+	if err != nil && (query.Session == supervisor.StatusOnline || query.Session == supervisor.StatusOffline) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "container runtime is unreachable"})
+		return
+	}
+
+	filtered, ok := scopeUsers(c, db.Model(&models.User{}).Joins("LEFT JOIN classes ON classes.id = users.class_id"))
+	if !ok {
+		return
+	}
+	if query.ClassID != "" {
+		filtered = filtered.Where("users.class_id = ?", query.ClassID)
+	}
+	if query.Rights != "" {
+		filtered = filtered.Where("users.rights = ?", query.Rights)
+	}
+	if query.Search != "" {
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query.Search) + "%"
+		filtered = filtered.Where("users.surname ILIKE ?", pattern)
+	}
+	if query.Session != "" {
+		const hasSession = "EXISTS (SELECT 1 FROM sessions WHERE sessions.user_id = users.id AND sessions.deleted_at IS NULL"
+		containerIDs := make([]string, 0, len(running))
+		for id := range running {
+			containerIDs = append(containerIDs, id)
+		}
+		switch query.Session {
+		case supervisor.StatusNone:
+			filtered = filtered.Where("NOT " + hasSession + ")")
+		case supervisor.StatusOnline:
+			filtered = filtered.Where(hasSession+" AND sessions.container_id IN ?)", containerIDs)
+		case supervisor.StatusOffline:
+			// NOT IN с пустым списком не совпадает ни с чем
+			if len(containerIDs) == 0 {
+				filtered = filtered.Where(hasSession + ")")
+			} else {
+				filtered = filtered.Where(hasSession+" AND sessions.container_id NOT IN ?)", containerIDs)
+			}
+		}
+	}
+	filtered = filtered.Session(&gorm.Session{})
+
+	if err := filtered.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't count users"})
+		return
+	}
+
+	if err := filtered.Preload("Session").Preload("Class").
+		Order(usersSorts[query.Sort] + " " + query.Order).Order("users.id").
+		Offset((query.Page - 1) * query.PerPage).Limit(query.PerPage).
+		Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't load users"})
+		return
+	}
+
+	response := make([]StudentResponse, 0, len(users))
+	for i := range users {
+		item := StudentResponse{User: users[i]}
+		if running != nil {
+			item.SessionStatus = supervisor.SessionStatus(&users[i], running)
+		}
+		response = append(response, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users":    response,
+		"total":    total,
+		"page":     query.Page,
+		"per_page": query.PerPage,
+	})
+}
+
+// scopeUsers restricts users query to users that current user can manage: teachers
+// see only students of their classes. Writes an error response on failure.
+func scopeUsers(c *gin.Context, query *gorm.DB) (*gorm.DB, bool) {
+	user := currentUser(c)
+	if user == nil || user.Rights != models.RightsTeacher {
+		return query, true
+	}
+
+	classes, err := user.Classes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't load teacher classes"})
+		return nil, false
+	}
+	ids := make([]string, 0, len(classes))
+	for _, class := range classes {
+		ids = append(ids, class.ID)
+	}
+	return query.Where("rights = ? AND class_id IN ?", models.RightsStudent, ids), true
 }
@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"archive/zip"
+	"bytes"
+	"gradio/models"
+	"gradio/supervisor"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type assignmentURI struct {
+	ID string `uri:"id" binding:"required,uuid"`
+}
+
+// GetAssignments returns assignment catalog, it's readable by every user, so
+// students can choose assignment of their session
+func GetAssignments(c *gin.Context) {
+	var (
+		db          = models.GetDB()
+		assignments []models.Assignment
+	)
+
+	if err := models.FindAssignments(db.Order("created_at"), &assignments); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't load assignments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"assignments": assignments})
+}
+
+func GetAssignment(c *gin.Context) {
+	var (
+		db          = models.GetDB()
+		uri         assignmentURI
+		assignments []models.Assignment
+	)
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := models.FindAssignments(db.Where("id = ?", uri.ID), &assignments); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't load assignment"})
+		return
+	}
+	if len(assignments) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "assignment with this id not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"assignment": assignments[0]})
+}
+
+func AddAssignment(c *gin.Context) {
+	var (
+		db   = models.GetDB()
+		data struct {
+			Title       string     `json:"title" binding:"required,max=256"`
+			Description string     `json:"description" binding:"omitempty"`
+			Profile     string     `json:"profile" binding:"omitempty,max=64"`
+			Deadline    *time.Time `json:"deadline" binding:"omitempty"`
+			TimeLimit   int        `json:"time_limit" binding:"omitempty,gte=0"`
+		}
+	)
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := supervisor.FindProfile(data.Profile); !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": "image profile with this name not found"})
+		return
+	}
+
+	assignment := models.Assignment{
+		Title:       data.Title,
+		Description: data.Description,
+		Profile:     data.Profile,
+		Deadline:    data.Deadline,
+		TimeLimit:   data.TimeLimit,
+	}
+
+	if err := db.Create(&assignment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't create assignment in database"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"assignment": assignment})
+}
+
+func UpdateAssignment(c *gin.Context) {
+	var (
+		db         = models.GetDB()
+		uri        assignmentURI
+		assignment models.Assignment
+		data       struct {
+			Title       *string    `json:"title" binding:"omitempty,min=1,max=256"`
+			Description *string    `json:"description" binding:"omitempty"`
+			Profile     *string    `json:"profile" binding:"omitempty,max=64"`
+			Deadline    *time.Time `json:"deadline" binding:"omitempty"`
+			TimeLimit   *int       `json:"time_limit" binding:"omitempty,gte=0"`
+		}
+	)
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.First(&assignment, "id = ?", uri.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "assignment with this id not found"})
+		return
+	}
+
+	if data.Profile != nil {
+		if _, ok := supervisor.FindProfile(*data.Profile); !ok {
+			c.JSON(http.StatusConflict, gin.H{"error": "image profile with this name not found"})
+			return
+		}
+	}
+
+	if data.Title != nil {
+		assignment.Title = *data.Title
+	}
+	if data.Description != nil {
+		assignment.Description = *data.Description
+	}
+	if data.Profile != nil {
+		assignment.Profile = *data.Profile
+	}
+	if data.Deadline != nil {
+		assignment.Deadline = data.Deadline
+	}
+	if data.TimeLimit != nil {
+		assignment.TimeLimit = *data.TimeLimit
+	}
+
+	if err := db.Save(&assignment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't update assignment in database"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"assignment": assignment})
+}
+
+func DelAssignment(c *gin.Context) {
+	var (
+		db         = models.GetDB()
+		uri        assignmentURI
+		assignment models.Assignment
+	)
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.First(&assignment, "id = ?", uri.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "assignment with this id not found"})
+		return
+	}
+
+	if err := db.Delete(&assignment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error on delete assignment"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// UploadStarter replaces assignment starter files with zip archive from form
+func UploadStarter(c *gin.Context) {
+	var (
+		db         = models.GetDB()
+		uri        assignmentURI
+		assignment models.Assignment
+	)
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.First(&assignment, "id = ?", uri.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "assignment with this id not found"})
+		return
+	}
+
+	file, ok := uploadedFile(c)
+	if !ok {
+		return
+	}
+
+	content, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer content.Close()
+
+	data, err := io.ReadAll(content)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := zip.NewReader(bytes.NewReader(data), int64(len(data))); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "starter files must be a zip archive"})
+		return
+	}
+
+	if err := db.Model(&assignment).Update("starter", data).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't save starter files"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"starter_dir": supervisor.StarterDir(&assignment), "size": len(data)})
+}
@@ -0,0 +1,288 @@
+package controllers
+
+import (
+	"errors"
+	"gradio/containers"
+	"gradio/models"
+	"gradio/supervisor"
+	"net/http"
+
+	"github.com/docker/go-units"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type classURI struct {
+	ID string `uri:"id" binding:"required,uuid"`
+}
+
+// limitsRequest is a resource limits of class or image profile, memory is a
+// size like 512m or 2g
+type limitsRequest struct {
+	CPUs   float64 `json:"cpus" binding:"gte=0"`
+	Memory string  `json:"memory" binding:"omitempty"`
+	Pids   int64   `json:"pids" binding:"gte=0"`
+	Disk   string  `json:"disk" binding:"omitempty"`
+}
+
+// limits converts request into limits, writes an error response if memory is bad
+func (r limitsRequest) limits(c *gin.Context) (models.Limits, bool) {
+	limits := models.Limits{CPUs: r.CPUs, Pids: r.Pids, Disk: r.Disk}
+	if r.Memory != "" {
+		memory, err := units.RAMInBytes(r.Memory)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return models.Limits{}, false
+		}
+		limits.Memory = memory
+	}
+	return limits, true
+}
+
+// StudentResponse is a student with status of the session
+type StudentResponse struct {
+	models.User
+	SessionStatus string `json:"session_status,omitempty"`
+}
+
+func GetClasses(c *gin.Context) {
+	var (
+		db      = models.GetDB()
+		user    = currentUser(c)
+		classes []models.Class
+		err     error
+	)
+
+	// Учитель видит только свои классы
+	if user != nil && user.Rights == models.RightsTeacher {
+		classes, err = user.Classes()
+	} else {
+		err = db.Order("name").Find(&classes).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't load classes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"classes": classes})
+}
+
+func GetClass(c *gin.Context) {
+	var (
+		db    = models.GetDB()
+		uri   classURI
+		class models.Class
+	)
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !canSeeClass(c, uri.ID) {
+		return
+	}
+
+	if db.Preload("Teachers").First(&class, "id = ?", uri.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "class with this id not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"class": class})
+}
+
+func AddClass(c *gin.Context) {
+	var (
+		db   = models.GetDB()
+		data struct {
+			Name   string        `json:"name" binding:"required,max=64"`
+			Limits limitsRequest `json:"limits"`
+		}
+	)
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := models.FindClass(data.Name); ok {
+		c.JSON(http.StatusConflict, gin.H{"error": "class with this name already exist"})
+		return
+	}
+
+	limits, ok := data.Limits.limits(c)
+	if !ok {
+		return
+	}
+
+	class := models.Class{Name: data.Name, Limits: limits}
+	if err := db.Create(&class).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't create class in database"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"class": class})
+}
+
+// UpdateClass renames class and replaces its resource limits if they are given
+func UpdateClass(c *gin.Context) {
+	var (
+		db    = models.GetDB()
+		uri   classURI
+		class models.Class
+		data  struct {
+			Name   string         `json:"name" binding:"required,max=64"`
+			Limits *limitsRequest `json:"limits"`
+		}
+	)
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.First(&class, "id = ?", uri.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "class with this id not found"})
+		return
+	}
+
+	if other, ok := models.FindClass(data.Name); ok && other.ID != class.ID {
+		c.JSON(http.StatusConflict, gin.H{"error": "class with this name already exist"})
+		return
+	}
+
+	class.Name = data.Name
+	if data.Limits != nil {
+		limits, ok := data.Limits.limits(c)
+		if !ok {
+			return
+		}
+		class.Limits = limits
+	}
+	if err := db.Save(&class).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't update class in database"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"class": class})
+}
+
+func DelClass(c *gin.Context) {
+	var (
+		db       = models.GetDB()
+		uri      classURI
+		class    models.Class
+		students int64
+	)
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.First(&class, "id = ?", uri.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "class with this id not found"})
+		return
+	}
+
+	if db.Model(&models.User{}).Where("class_id = ?", class.ID).Count(&students); students != 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "class has students, move or delete them first"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&class).Association("Teachers").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(&class).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error on delete class"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// GetClassStudents returns students of class with status of their sessions
+func (h *Handlers) GetClassStudents(c *gin.Context) {
+	var (
+		db    = models.GetDB()
+		uri   classURI
+		class models.Class
+		users []models.User
+	)
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !canSeeClass(c, uri.ID) {
+		return
+	}
+
+	if db.First(&class, "id = ?", uri.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "class with this id not found"})
+		return
+	}
+
+	if err := db.Preload("Session").Order("surname").
+		Find(&users, "class_id = ? AND rights = ?", class.ID, models.RightsStudent).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't load students"})
+		return
+	}
+
+	statuses, err := supervisor.SessionStatuses(c, h.rt, users)
+	switch {
+	case errors.Is(err, containers.ErrUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "container runtime is unreachable"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't get session statuses"})
+		return
+	}
+
+	students := make([]StudentResponse, 0, len(users))
+	for _, user := range users {
+		students = append(students, StudentResponse{user, statuses[user.ID]})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"class": class, "students": students})
+}
+
+// canSeeClass checks that teacher is assigned to class, writes an error response if not
+func canSeeClass(c *gin.Context, classID string) bool {
+	if user := currentUser(c); user != nil && user.Rights == models.RightsTeacher && !user.Teaches(classID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "class is not assigned to you"})
+		return false
+	}
+	return true
+}
+
+// resolveClass finds class by id or by name if id is empty, writes an error
+// response if class is not found. Nil class is returned if both are empty.
+func resolveClass(c *gin.Context, id, name string) (*models.Class, bool) {
+	var class models.Class
+	switch {
+	case id != "":
+		if models.GetDB().First(&class, "id = ?", id).RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "class with this id not found"})
+			return nil, false
+		}
+		return &class, true
+	case name != "":
+		class, ok := models.FindClass(name)
+		if !ok {
+			c.JSON(http.StatusConflict, gin.H{"error": "class with this name not found"})
+			return nil, false
+		}
+		return class, true
+	}
+	return nil, true
+}
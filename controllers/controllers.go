@@ -1,11 +1,44 @@
 package controllers
 
 import (
+	"gradio/containers"
+	"gradio/models"
 	"net/http"
 
+	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
 )
 
+// Handlers contains handlers that manage student containers
+type Handlers struct {
+	// rt is a container runtime that runs student sessions
+	rt containers.Runtime
+}
+
+// NewHandlers creates handlers of sessions running in runtime
+func NewHandlers(rt containers.Runtime) *Handlers {
+	return &Handlers{rt: rt}
+}
+
+// currentUser returns user authorized by JWT middleware or nil
+func currentUser(c *gin.Context) *models.User {
+	if identity, ok := c.Get(jwt.IdentityKey); ok {
+		if user, ok := identity.(*models.User); ok {
+			return user
+		}
+	}
+	return nil
+}
+
+// canManage checks that current user can manage the user, writes an error response if not
+func canManage(c *gin.Context, user *models.User) bool {
+	if manager := currentUser(c); manager == nil || !manager.Manages(user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "user is out of your classes"})
+		return false
+	}
+	return true
+}
+
 func NotImplemented(c *gin.Context) {
 	c.JSON(http.StatusNotImplemented, gin.H{"status": "not implemented"})
 }
@@ -0,0 +1,328 @@
+package controllers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"gradio/containers"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+type FileEntry struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Dir      bool      `json:"dir"`
+	Modified time.Time `json:"modified"`
+}
+
+// listDirScript prints "dir", "file" or "missing" for path $1 on the first line,
+// then entries of directory separated by NUL: type, size, modification time and name
+const listDirScript = `[ -e "$1" ] || { echo missing; exit; }
+[ -d "$1" ] || { echo file; exit; }
+echo dir
+exec find "$1" -mindepth 1 -maxdepth 1 -printf '%y\t%s\t%T@\t%P\0'`
+
+// ListFiles returns entries of workspace directory. Directory is listed inside
+// the container, so file contents are not copied through gradio.
+func (h *Handlers) ListFiles(c *gin.Context) {
+	session, ok := ownedSession(c)
+	if !ok {
+		return
+	}
+
+	out, err := h.rt.Exec(c, session.ContainerID, []string{"sh", "-c", listDirScript, "sh", workspacePath(c.Query("path"))})
+	if !checkFileError(c, err) {
+		return
+	}
+
+	files, err := parseFileList(out)
+	switch {
+	case errors.Is(err, containers.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+	case errors.Is(err, errNotDir):
+		c.JSON(http.StatusConflict, gin.H{"error": "path is not a directory"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't read workspace files"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"files": files})
+	}
+}
+
+var errNotDir = errors.New("path is not a directory")
+
+// parseFileList parses output of listDirScript
+func parseFileList(out []byte) ([]FileEntry, error) {
+	lines := strings.SplitN(string(out), "\n", 2)
+	switch lines[0] {
+	case "dir":
+	case "missing":
+		return nil, containers.ErrNotFound
+	case "file":
+		return nil, errNotDir
+	default:
+		return nil, fmt.Errorf("unexpected directory state %q", lines[0])
+	}
+
+	files := []FileEntry{}
+	if len(lines) < 2 {
+		return files, nil
+	}
+	for _, entry := range strings.Split(lines[1], "\x00") {
+		if entry == "" {
+			continue
+		}
+		// Имя последнее, в нем могут быть табуляции
+		fields := strings.SplitN(entry, "\t", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("bad directory entry %q", entry)
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		modified, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := FileEntry{
+			Path:     fields[3],
+			Size:     size,
+			Dir:      fields[0] == "d",
+			Modified: time.Unix(0, int64(modified*float64(time.Second))),
+		}
+		// Размер каталога зависит от файловой системы, как и в архивах он не отдается
+		if entry.Dir {
+			entry.Size = 0
+		}
+		files = append(files, entry)
+	}
+	return files, nil
+}
+
+func (h *Handlers) DownloadFile(c *gin.Context) {
+	session, ok := ownedSession(c)
+	if !ok {
+		return
+	}
+
+	out, stat, err := h.rt.CopyFrom(c, session.ContainerID, workspacePath(c.Query("path")))
+	if !checkFileError(c, err) {
+		return
+	}
+	defer out.Close()
+
+	if !stat.Mode.IsRegular() {
+		c.JSON(http.StatusConflict, gin.H{"error": "path is not a file"})
+		return
+	}
+
+	if stat.Size > sizeLimit("files.max_download_size") {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file is too large"})
+		return
+	}
+
+	tr := tar.NewReader(out)
+	hdr, err := tr.Next()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't read workspace file"})
+		return
+	}
+
+	c.DataFromReader(http.StatusOK, hdr.Size, "application/octet-stream", tr, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": stat.Name}),
+	})
+}
+
+func (h *Handlers) DownloadArchive(c *gin.Context) {
+	session, ok := ownedSession(c)
+	if !ok {
+		return
+	}
+
+	out, stat, err := h.rt.CopyFrom(c, session.ContainerID, workspacePath(c.Query("path")))
+	if !checkFileError(c, err) {
+		return
+	}
+	defer out.Close()
+
+	var (
+		buf   bytes.Buffer
+		zw    = zip.NewWriter(&buf)
+		tr    = tar.NewReader(out)
+		limit = sizeLimit("files.max_download_size")
+		total int64
+	)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "can't read workspace files"})
+			return
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if total += hdr.Size; total > limit {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "files are too large"})
+			return
+		}
+
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: hdr.Name, Method: zip.Deflate, Modified: hdr.ModTime})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "can't create archive"})
+			return
+		}
+		if _, err := io.Copy(w, tr); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "can't create archive"})
+			return
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't create archive"})
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": stat.Name + ".zip"}))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+func (h *Handlers) UploadFile(c *gin.Context) {
+	session, ok := ownedSession(c)
+	if !ok {
+		return
+	}
+
+	file, ok := uploadedFile(c)
+	if !ok {
+		return
+	}
+
+	name := path.Base(strings.ReplaceAll(file.Filename, "\\", "/"))
+	if name == "." || name == "/" || name == ".." {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad file name"})
+		return
+	}
+
+	content, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer content.Close()
+
+	// Каталоги создаются из архива, копируем всегда в корень рабочей папки
+	var (
+		buf bytes.Buffer
+		tw  = tar.NewWriter(&buf)
+		dir = strings.TrimPrefix(path.Clean("/"+c.PostForm("path")), "/")
+		now = time.Now()
+		uid = viper.GetInt("workspace.uid")
+		gid = viper.GetInt("workspace.gid")
+	)
+	if dir != "" {
+		parts := strings.Split(dir, "/")
+		for i := range parts {
+			if err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     strings.Join(parts[:i+1], "/") + "/",
+				Mode:     0755,
+				Uid:      uid,
+				Gid:      gid,
+				ModTime:  now,
+			}); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "can't pack file"})
+				return
+			}
+		}
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     path.Join(dir, name),
+		Mode:     0644,
+		Size:     file.Size,
+		Uid:      uid,
+		Gid:      gid,
+		ModTime:  now,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't pack file"})
+		return
+	}
+	if _, err := io.Copy(tw, content); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't pack file"})
+		return
+	}
+	if err := tw.Close(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't pack file"})
+		return
+	}
+
+	if !checkFileError(c, h.rt.CopyTo(c, session.ContainerID, workspacePath(""), &buf)) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"path": path.Join(dir, name), "size": file.Size})
+}
+
+// workspacePath converts path relative to the student home into container path,
+// path can't leave the home
+func workspacePath(rel string) string {
+	return path.Join(viper.GetString("workspace.path"), path.Clean("/"+rel))
+}
+
+// sizeLimit returns size from config in bytes
+func sizeLimit(key string) int64 {
+	size, _ := units.RAMInBytes(viper.GetString(key))
+	return size
+}
+
+// uploadedFile returns file of multipart form field "file", request body is
+// limited by files.max_upload_size. Writes an error response if file is missing
+// or too large.
+func uploadedFile(c *gin.Context) (*multipart.FileHeader, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, sizeLimit("files.max_upload_size"))
+	file, err := c.FormFile("file")
+
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file is larger than upload limit"})
+		return nil, false
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return file, true
+}
+
+// checkFileError writes an error response for copy errors and reports if there was no error
+func checkFileError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, containers.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+	case errors.Is(err, containers.ErrUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "container runtime is unreachable"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't access workspace files"})
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"errors"
+	"gradio/containers"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestWorkspacePath(t *testing.T) {
+	tests := []struct {
+		rel  string
+		want string
+	}{
+		{"", "/home/student"},
+		{"lab1/fm.grc", "/home/student/lab1/fm.grc"},
+		{"/lab1/", "/home/student/lab1"},
+		{"../../etc/passwd", "/home/student/etc/passwd"},
+		{"lab1/../../..", "/home/student"},
+	}
+
+	viper.Set("workspace.path", "/home/student")
+	for _, tt := range tests {
+		if got := workspacePath(tt.rel); got != tt.want {
+			t.Errorf("workspacePath(%q) = %q, want %q", tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestParseFileList(t *testing.T) {
+	modified := time.Unix(1700000000, 500000000)
+
+	tests := []struct {
+		name    string
+		out     string
+		files   []FileEntry
+		wantErr error
+	}{
+		{
+			name: "directory",
+			out:  "dir\nf\t3\t1700000000.5000000000\ta.txt\x00d\t4096\t1700000000.5000000000\tlab 1\x00f\t1\t1700000000.5000000000\tt\tab\x00",
+			files: []FileEntry{
+				{Path: "a.txt", Size: 3, Modified: modified},
+				{Path: "lab 1", Dir: true, Modified: modified},
+				{Path: "t\tab", Size: 1, Modified: modified},
+			},
+		},
+		{name: "empty directory", out: "dir\n", files: []FileEntry{}},
+		{name: "file", out: "file\n", wantErr: errNotDir},
+		{name: "missing", out: "missing\n", wantErr: containers.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		files, err := parseFileList([]byte(tt.out))
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if len(files) != len(tt.files) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, files, tt.files)
+			continue
+		}
+		for i := range files {
+			if files[i].Path != tt.files[i].Path || files[i].Size != tt.files[i].Size ||
+				files[i].Dir != tt.files[i].Dir || !files[i].Modified.Equal(tt.files[i].Modified) {
+				t.Errorf("%s: entry %d = %+v, want %+v", tt.name, i, files[i], tt.files[i])
+			}
+		}
+	}
+
+	for _, out := range []string{"", "denied\n", "dir\nf\tbig\t0\tname\x00", "dir\nf\t1\x00"} {
+		if _, err := parseFileList([]byte(out)); err == nil {
+			t.Errorf("bad output %q is parsed without error", out)
+		}
+	}
+}
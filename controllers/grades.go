@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"fmt"
+	"gradio/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+type gradeURI struct {
+	ID      string `uri:"id" binding:"required,uuid"`
+	GradeID string `uri:"grade_id" binding:"required,uuid"`
+}
+
+func GetGrades(c *gin.Context) {
+	var (
+		db   = models.GetDB()
+		user models.User
+		data struct {
+			ID string `uri:"id" binding:"required,uuid"`
+		}
+	)
+
+	if err := c.ShouldBindUri(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.Preload("Grades", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("created_at")
+	}).First(&user, "id = ?", data.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "user with this id not found"})
+		return
+	}
+
+	if !canManage(c, &user) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"grades": user.Grades})
+}
+
+func AddGrade(c *gin.Context) {
+	var (
+		db   = models.GetDB()
+		user models.User
+		uri  struct {
+			ID string `uri:"id" binding:"required,uuid"`
+		}
+		data struct {
+			Mark         *int   `json:"mark" binding:"required"`
+			Assignment   string `json:"assignment" binding:"required_without=AssignmentID,max=256"`
+			AssignmentID string `json:"assignment_id" binding:"omitempty,uuid"`
+			Comment      string `json:"comment" binding:"omitempty"`
+		}
+	)
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := checkMark(*data.Mark); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.First(&user, "id = ?", uri.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "user with this id not found"})
+		return
+	}
+
+	if !canManage(c, &user) {
+		return
+	}
+
+	grade := models.Grade{
+		UserID:     user.ID,
+		Mark:       *data.Mark,
+		Assignment: data.Assignment,
+		Comment:    data.Comment,
+	}
+	if data.AssignmentID != "" && !linkAssignment(c, &grade, data.AssignmentID) {
+		return
+	}
+	if grader := currentUser(c); grader != nil {
+		grade.GraderID = &grader.ID
+	}
+
+	if err := db.Create(&grade).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't create grade in database"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"grade": grade})
+}
+
+func UpdateGrade(c *gin.Context) {
+	var (
+		db    = models.GetDB()
+		user  models.User
+		grade models.Grade
+		uri   gradeURI
+		data  struct {
+			Mark         *int    `json:"mark" binding:"omitempty"`
+			Assignment   *string `json:"assignment" binding:"omitempty,max=256"`
+			AssignmentID *string `json:"assignment_id" binding:"omitempty,uuid"`
+			Comment      *string `json:"comment" binding:"omitempty"`
+		}
+	)
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.First(&user, "id = ?", uri.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "user with this id not found"})
+		return
+	}
+
+	if !canManage(c, &user) {
+		return
+	}
+
+	if db.First(&grade, "id = ? AND user_id = ?", uri.GradeID, uri.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "grade with this id not found"})
+		return
+	}
+
+	if data.Mark != nil {
+		if err := checkMark(*data.Mark); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		grade.Mark = *data.Mark
+	}
+	if data.Assignment != nil {
+		grade.Assignment = *data.Assignment
+	}
+	if data.AssignmentID != nil && !linkAssignment(c, &grade, *data.AssignmentID) {
+		return
+	}
+	if data.Comment != nil {
+		grade.Comment = *data.Comment
+	}
+	if grader := currentUser(c); grader != nil {
+		grade.GraderID = &grader.ID
+	}
+
+	if err := db.Save(&grade).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't update grade in database"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"grade": grade})
+}
+
+func DelGrade(c *gin.Context) {
+	var (
+		db    = models.GetDB()
+		user  models.User
+		grade models.Grade
+		uri   gradeURI
+	)
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.First(&user, "id = ?", uri.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "user with this id not found"})
+		return
+	}
+
+	if !canManage(c, &user) {
+		return
+	}
+
+	if db.First(&grade, "id = ? AND user_id = ?", uri.GradeID, uri.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "grade with this id not found"})
+		return
+	}
+
+	if err := db.Delete(&grade).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error on delete grade"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// linkAssignment links grade with assignment and takes its title, writes an
+// error response if assignment is not found
+func linkAssignment(c *gin.Context, grade *models.Grade, assignmentID string) bool {
+	var assignment models.Assignment
+	if models.GetDB().First(&assignment, "id = ?", assignmentID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "assignment with this id not found"})
+		return false
+	}
+	grade.AssignmentID = &assignment.ID
+	grade.Assignment = assignment.Title
+	return true
+}
+
+// checkMark checks that mark is in configured grade scale
+func checkMark(mark int) error {
+	min, max := viper.GetInt("grades.min"), viper.GetInt("grades.max")
+	if mark < min || mark > max {
+		return fmt.Errorf("mark must be from %d to %d", min, max)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"gradio/models"
+	"gradio/supervisor"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImageResponse struct {
+	models.ImageProfile
+	Pull supervisor.PullState `json:"pull"`
+}
+
+func GetImages(c *gin.Context) {
+	var (
+		db       = models.GetDB()
+		profiles []models.ImageProfile
+	)
+
+	if err := db.Order("name").Find(&profiles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't load image profiles"})
+		return
+	}
+
+	response := make([]ImageResponse, 0, len(profiles))
+	for _, profile := range profiles {
+		response = append(response, ImageResponse{profile, supervisor.PullStatus(profile.Image)})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"images": response})
+}
+
+func (h *Handlers) AddImage(c *gin.Context) {
+	var (
+		db   = models.GetDB()
+		data struct {
+			Name             string        `json:"name" binding:"required,max=64"`
+			Image            string        `json:"image" binding:"required"`
+			RegistryUser     string        `json:"registry_user" binding:"omitempty"`
+			RegistryPassword string        `json:"registry_password" binding:"omitempty"`
+			VNCPort          int           `json:"vnc_port" binding:"omitempty,gte=1,lte=65535"`
+			Env              []string      `json:"env" binding:"omitempty"`
+			Limits           limitsRequest `json:"limits"`
+		}
+	)
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := supervisor.FindProfile(data.Name); ok {
+		c.JSON(http.StatusConflict, gin.H{"error": "image profile with this name already exist"})
+		return
+	}
+
+	profile := models.ImageProfile{
+		Name:         data.Name,
+		Image:        data.Image,
+		RegistryUser: data.RegistryUser,
+		VNCPort:      data.VNCPort,
+		Env:          data.Env,
+	}
+	if profile.VNCPort == 0 {
+		profile.VNCPort = supervisor.VNCPort
+	}
+	limits, ok := data.Limits.limits(c)
+	if !ok {
+		return
+	}
+	profile.Limits = limits
+	if err := profile.SetRegistryPassword(data.RegistryPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't encrypt registry password"})
+		return
+	}
+
+	if err := db.Create(&profile).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't create image profile in database"})
+		return
+	}
+
+	supervisor.PullProfile(h.rt, &profile)
+
+	c.JSON(http.StatusOK, gin.H{"image": ImageResponse{profile, supervisor.PullStatus(profile.Image)}})
+}
+
+func DelImage(c *gin.Context) {
+	var (
+		db      = models.GetDB()
+		profile models.ImageProfile
+		data    struct {
+			ID string `uri:"id" binding:"required,uuid"`
+		}
+	)
+
+	if err := c.ShouldBindUri(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.First(&profile, "id = ?", data.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "image profile with this id not found"})
+		return
+	}
+
+	if profile.Name == models.DefaultProfile {
+		c.JSON(http.StatusConflict, gin.H{"error": "default image profile can't be deleted"})
+		return
+	}
+
+	if err := db.Delete(&profile).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error on delete image profile"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// GetImagePull returns pull progress of profile image
+func (h *Handlers) GetImagePull(c *gin.Context) {
+	var (
+		db      = models.GetDB()
+		profile models.ImageProfile
+		data    struct {
+			ID string `uri:"id" binding:"required,uuid"`
+		}
+	)
+
+	if err := c.ShouldBindUri(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.First(&profile, "id = ?", data.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "image profile with this id not found"})
+		return
+	}
+
+	present, err := h.rt.HasImage(c, profile.Image)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "container runtime is unreachable"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pull": supervisor.PullStatus(profile.Image), "present": present})
+}
+
+func (h *Handlers) PullImage(c *gin.Context) {
+	var (
+		db      = models.GetDB()
+		profile models.ImageProfile
+		data    struct {
+			ID string `uri:"id" binding:"required,uuid"`
+		}
+	)
+
+	if err := c.ShouldBindUri(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.First(&profile, "id = ?", data.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "image profile with this id not found"})
+		return
+	}
+
+	supervisor.PullProfile(h.rt, &profile)
+
+	c.JSON(http.StatusOK, gin.H{"pull": supervisor.PullStatus(profile.Image)})
+}
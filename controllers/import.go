@@ -0,0 +1,355 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"gradio/models"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// utf8BOM is written to exported CSV so Excel detects encoding
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+var (
+	errImportRows = errors.New("import has invalid rows")
+	errDryRun     = errors.New("dry run")
+)
+
+// rowError is a validation error of one imported row
+type rowError string
+
+func (e rowError) Error() string {
+	return string(e)
+}
+
+// ImportRow is a result of import of one CSV row
+type ImportRow struct {
+	Line      int    `json:"line"`
+	ID        string `json:"id,omitempty"`
+	Login     string `json:"login,omitempty"`
+	Surname   string `json:"surname"`
+	GivenName string `json:"given_name"`
+	Class     string `json:"class"`
+	Password  string `json:"password,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ImportUsers creates students from CSV with columns surname, given_name, class
+// and optional password. Nothing is created if any row is invalid or in dry run.
+func ImportUsers(c *gin.Context) {
+	var (
+		db      = models.GetDB()
+		manager = currentUser(c)
+		query   struct {
+			DryRun bool `form:"dry_run"`
+		}
+		rows []ImportRow
+	)
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	file, ok := uploadedFile(c)
+	if !ok {
+		return
+	}
+
+	content, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer content.Close()
+
+	records, lines, err := readUsersCSV(content)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Строки создаются в транзакции, чтобы проверить уникальность и в пределах файла
+	err = db.Transaction(func(tx *gorm.DB) error {
+		failed := false
+		for i, record := range records {
+			row := ImportRow{Line: lines[i]}
+			var invalid rowError
+			if err := importUser(tx, manager, record, &row); errors.As(err, &invalid) {
+				row.Error, failed = invalid.Error(), true
+			} else if err != nil {
+				return err
+			}
+			rows = append(rows, row)
+		}
+
+		switch {
+		case failed:
+			return errImportRows
+		case query.DryRun:
+			return errDryRun
+		}
+		return nil
+	})
+
+	switch {
+	case errors.Is(err, errImportRows):
+		for i := range rows {
+			rows[i].ID, rows[i].Password = "", ""
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": errImportRows.Error(), "rows": rows})
+		return
+	case errors.Is(err, errDryRun):
+		// Пароли пробного импорта не сохранены, не показываем их
+		for i := range rows {
+			rows[i].ID, rows[i].Password = "", ""
+		}
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "rows": rows})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't import users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rows": rows})
+}
+
+// importUser validates CSV record and creates student in tx, validation
+// errors are returned as rowError
+func importUser(tx *gorm.DB, manager *models.User, record []string, row *ImportRow) error {
+	for len(record) < 4 {
+		record = append(record, "")
+	}
+	row.Surname = strings.TrimSpace(record[0])
+	row.GivenName = strings.TrimSpace(record[1])
+	row.Class = strings.TrimSpace(record[2])
+	password := strings.TrimSpace(record[3])
+
+	switch {
+	case row.Surname == "":
+		return rowError("surname is required")
+	case row.GivenName == "":
+		return rowError("given_name is required")
+	case row.Class == "":
+		return rowError("class is required")
+	case password != "" && !models.ValidPassword(password):
+		return rowError("password must be 4 to 72 characters without spaces")
+	}
+
+	class, ok := models.FindClass(row.Class)
+	if !ok {
+		return rowError("class not found")
+	}
+
+	user := models.User{
+		Rights:    models.RightsStudent,
+		Surname:   row.Surname,
+		GivenName: &row.GivenName,
+		ClassID:   &class.ID,
+		Class:     class,
+	}
+	if manager == nil || !manager.Manages(&user) {
+		return rowError("class is not assigned to you")
+	}
+
+	var count int64
+	if err := tx.Model(&models.User{}).Where("class_id = ? AND surname = ?", class.ID, row.Surname).Count(&count).Error; err != nil {
+		return err
+	}
+	if count != 0 {
+		return rowError("user with this class and surname already exist")
+	}
+
+	if err := user.GenLogin(tx); err != nil {
+		return err
+	}
+	if err := user.GenHash(password); err != nil {
+		return err
+	}
+	if err := tx.Omit("Class").Create(&user).Error; err != nil {
+		return err
+	}
+
+	row.ID, row.Login, row.Password = user.ID, user.Login, user.Password
+	return nil
+}
+
+// readUsersCSV reads records of users CSV, comma and semicolon separators are
+// supported, header is skipped. lines contains line numbers of records.
+func readUsersCSV(r io.Reader) (records [][]string, lines []int, err error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	content = bytes.TrimPrefix(content, utf8BOM)
+
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	// Excel с русской локалью сохраняет CSV через точку с запятой
+	firstLine := content
+	if i := bytes.IndexByte(content, '\n'); i >= 0 {
+		firstLine = content[:i]
+	}
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(records) == 0 && line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "surname") {
+			continue
+		}
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+
+	if len(records) == 0 {
+		return nil, nil, errors.New("file has no users")
+	}
+	return records, lines, nil
+}
+
+// ExportUsers returns users as CSV or XLSX for printing
+func ExportUsers(c *gin.Context) {
+	exportUsers(c, false)
+}
+
+// ExportUsersPasswords generates new passwords for exported students of the
+// class_id class and returns them in the file. It's a POST, so retried or
+// prefetched requests don't reset passwords.
+func ExportUsersPasswords(c *gin.Context) {
+	exportUsers(c, true)
+}
+
+// exportUsers writes users file, with resetPasswords passwords of students are
+// replaced with generated ones and included in the file
+func exportUsers(c *gin.Context, resetPasswords bool) {
+	var (
+		db    = models.GetDB()
+		users []models.User
+		query struct {
+			Format  string `form:"format" binding:"omitempty,oneof=csv xlsx"`
+			ClassID string `form:"class_id" binding:"omitempty,uuid"`
+			Rights  string `form:"rights" binding:"omitempty,oneof=admin teacher student"`
+		}
+	)
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Пароли сбрасываются по одному классу, а не всей школе одним запросом
+	if resetPasswords && query.ClassID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "class_id is required to reset passwords"})
+		return
+	}
+
+	scoped, ok := scopeUsers(c, db.Preload("Class"))
+	if !ok {
+		return
+	}
+	if query.ClassID != "" {
+		scoped = scoped.Where("class_id = ?", query.ClassID)
+	}
+	if query.Rights != "" {
+		scoped = scoped.Where("rights = ?", query.Rights)
+	}
+	if err := scoped.Order("surname").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't load users"})
+		return
+	}
+
+	if resetPasswords {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for i := range users {
+				if users[i].Rights != models.RightsStudent {
+					continue
+				}
+				if err := users[i].GenHash(""); err != nil {
+					return err
+				}
+				if err := tx.Model(&users[i]).UpdateColumn("hash", users[i].Hash).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "can't reset passwords"})
+			return
+		}
+	}
+
+	rows := [][]string{{"login", "surname", "given_name", "class", "rights"}}
+	if resetPasswords {
+		rows[0] = append(rows[0], "password")
+	}
+	for _, user := range users {
+		givenName := ""
+		if user.GivenName != nil {
+			givenName = *user.GivenName
+		}
+		row := []string{user.Login, user.Surname, givenName, user.ClassName(), user.Rights}
+		if resetPasswords {
+			row = append(row, user.Password)
+		}
+		rows = append(rows, row)
+	}
+
+	var (
+		buf         bytes.Buffer
+		contentType = "text/csv; charset=utf-8"
+		filename    = "users.csv"
+		err         error
+	)
+	if query.Format == "xlsx" {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		filename = "users.xlsx"
+		err = writeXLSX(&buf, rows)
+	} else {
+		buf.Write(utf8BOM)
+		w := csv.NewWriter(&buf)
+		for _, row := range rows {
+			cells := make([]string, len(row))
+			for i, cell := range row {
+				cells[i] = csvCell(cell)
+			}
+			w.Write(cells)
+		}
+		w.Flush()
+		err = w.Error()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't write users file"})
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// csvCell escapes value that spreadsheet would evaluate as formula, e.g. a
+// surname "=HYPERLINK(...)", by prefixing it with quote
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
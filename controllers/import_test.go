@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadUsersCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		records [][]string
+		lines   []int
+		wantErr bool
+	}{
+		{
+			name:    "comma with header",
+			content: "surname,given_name,class\nИванов, Иван,11А\nПетров,,\n",
+			records: [][]string{{"Иванов", "Иван", "11А"}, {"Петров", "", ""}},
+			lines:   []int{2, 3},
+		},
+		{
+			name:    "semicolon from excel",
+			content: "\ufeffSurname;Given_name;Class\r\nИванов;Иван;11А\r\n",
+			records: [][]string{{"Иванов", "Иван", "11А"}},
+			lines:   []int{2},
+		},
+		{
+			name:    "without header",
+			content: "Иванов,Иван,11А",
+			records: [][]string{{"Иванов", "Иван", "11А"}},
+			lines:   []int{1},
+		},
+		{name: "only header", content: "surname,given_name,class\n", wantErr: true},
+		{name: "empty", content: "", wantErr: true},
+		{name: "broken quotes", content: "\"Иванов,Иван\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		records, lines, err := readUsersCSV(strings.NewReader(tt.content))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(records, tt.records) || !reflect.DeepEqual(lines, tt.lines) {
+			t.Errorf("%s: got %q at lines %v, want %q at lines %v", tt.name, records, lines, tt.records, tt.lines)
+		}
+	}
+}
+
+func TestCSVCell(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Иванов", "Иванов"},
+		{"", ""},
+		{"=HYPERLINK(\"http://evil\")", "'=HYPERLINK(\"http://evil\")"},
+		{"+79990000000", "'+79990000000"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tcmd", "'\tcmd"},
+		{"Smith-Jones", "Smith-Jones"},
+	}
+
+	for _, tt := range tests {
+		if got := csvCell(tt.value); got != tt.want {
+			t.Errorf("csvCell(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
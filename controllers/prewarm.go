@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"gradio/models"
+	"gradio/supervisor"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// batchParallelism is a number of sessions started at once for a class
+const batchParallelism = 4
+
+// Результаты запуска сессии
+const (
+	SessionStarted  = "started"
+	SessionExisting = "existing"
+	SessionFailed   = "failed"
+)
+
+// adminSessionRequest is a body of session start on behalf of students
+type adminSessionRequest struct {
+	sessionRequest
+	// Lifetime of session in minutes
+	Lifetime int `json:"lifetime" binding:"omitempty,gte=1"`
+}
+
+// SessionResult is a result of session start on behalf of user
+type SessionResult struct {
+	UserID        string     `json:"user_id"`
+	Login         string     `json:"login"`
+	Surname       string     `json:"surname"`
+	Status        string     `json:"status"`
+	SessionID     string     `json:"session_id,omitempty"`
+	ConnectionURL string     `json:"connection_url,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	status        int
+}
+
+// adminSessionOptions binds request of session start on behalf of students,
+// writes an error response on failure
+func adminSessionOptions(c *gin.Context) (opts supervisor.Options, ok bool) {
+	var data adminSessionRequest
+	if err := c.ShouldBindJSON(&data); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return opts, false
+	}
+
+	if opts, ok = sessionOptions(c, data.sessionRequest); !ok {
+		return opts, false
+	}
+	opts.Lifetime = time.Duration(data.Lifetime) * time.Minute
+	// Студент подключится к заранее запущенной сессии только на занятии
+	opts.Prewarm = true
+	return opts, true
+}
+
+// startSession opens session of user, user session and class must be preloaded
+func (h *Handlers) startSession(ctx context.Context, user *models.User, opts supervisor.Options) SessionResult {
+	result := SessionResult{
+		UserID:  user.ID,
+		Login:   user.Login,
+		Surname: user.Surname,
+		Status:  SessionStarted,
+		status:  http.StatusOK,
+	}
+	if user.Session != nil {
+		result.Status = SessionExisting
+	}
+
+	session, err := supervisor.Open(ctx, h.rt, user, opts)
+	if err != nil {
+		result.Status = SessionFailed
+		result.status, result.Error = openError(err)
+		return result
+	}
+
+	result.SessionID = session.ID
+	result.ConnectionURL = session.ConnectionURL
+	result.ExpiresAt = session.ExpiresAt
+	return result
+}
+
+// StartSession starts session on behalf of user
+func (h *Handlers) StartSession(c *gin.Context) {
+	var (
+		db   = models.GetDB()
+		user models.User
+		data struct {
+			ID string `uri:"id" binding:"required,uuid"`
+		}
+	)
+
+	if err := c.ShouldBindUri(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.Preload("Session").Preload("Class").First(&user, "id = ?", data.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "user with this id not found"})
+		return
+	}
+
+	if !canManage(c, &user) {
+		return
+	}
+
+	opts, ok := adminSessionOptions(c)
+	if !ok {
+		return
+	}
+
+	result := h.startSession(c, &user, opts)
+	if result.Status == SessionFailed {
+		c.JSON(result.status, gin.H{"error": result.Error})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"session": result})
+}
+
+// StartClassSessions starts sessions of all class students, e.g. before a lesson
+func (h *Handlers) StartClassSessions(c *gin.Context) {
+	var (
+		db    = models.GetDB()
+		uri   classURI
+		class models.Class
+		users []models.User
+	)
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !canSeeClass(c, uri.ID) {
+		return
+	}
+
+	if db.First(&class, "id = ?", uri.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "class with this id not found"})
+		return
+	}
+
+	opts, ok := adminSessionOptions(c)
+	if !ok {
+		return
+	}
+
+	if err := db.Preload("Session").Preload("Class").Order("surname").
+		Find(&users, "class_id = ? AND rights = ?", class.ID, models.RightsStudent).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't load students"})
+		return
+	}
+
+	// Контекст запроса, а не gin.Context, используется из нескольких горутин
+	var (
+		ctx     = c.Request.Context()
+		results = make([]SessionResult, len(users))
+		wg      sync.WaitGroup
+		slots   = make(chan struct{}, batchParallelism)
+		failed  int
+	)
+	for i := range users {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer func() { <-slots; wg.Done() }()
+			results[i] = h.startSession(ctx, &users[i], opts)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		if result.Status == SessionFailed {
+			failed++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results, "failed": failed})
+}
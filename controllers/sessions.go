@@ -1,122 +1,221 @@
 package controllers
 
 import (
-	"context"
-	"fmt"
+	"crypto/subtle"
+	"errors"
+	"gradio/containers"
 	"gradio/models"
-	"gradio/tools"
-	"net"
+	"gradio/supervisor"
+	"io"
 	"net/http"
-	"strconv"
+	"time"
 
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
-	"github.com/docker/go-connections/nat"
 	"github.com/gin-gonic/gin"
-	"github.com/spf13/viper"
 )
 
-func GetStatusOfSession(c *gin.Context) {
-	var (
-		db   = models.GetDB()
-		data struct {
-			ID string `uri:"id" binding:"required,uuid"`
-		}
-		session models.Session
-	)
-
-	if err := c.ShouldBindUri(&data); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+func (h *Handlers) GetStatusOfSession(c *gin.Context) {
+	session, ok := ownedSession(c)
+	if !ok {
 		return
 	}
 
-	if db.First(&session, "id = ?", data.ID).RowsAffected == 0 {
-		c.JSON(http.StatusConflict, gin.H{"error": "session with this id not found"})
+	info, err := h.rt.Inspect(c, session.ContainerID)
+	if errors.Is(err, containers.ErrUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "container runtime is unreachable"})
 		return
-	}
-
-	if _, err := net.Dial("tcp", viper.GetString("external_host")+":"+strconv.Itoa(int(session.Port))); err != nil {
-		c.JSON(http.StatusOK, gin.H{"status": "offline"})
+	} else if err != nil || !info.Running {
+		c.JSON(http.StatusOK, gin.H{"status": "offline", "limits": session.Limits})
 		return
 	}
 
+	// Активность сессии отмечают только VNC подключения, опрос статуса не продлевает сессию
 	c.JSON(http.StatusOK, gin.H{
 		"status":         "online",
 		"connection_url": session.ConnectionURL,
+		"vnc_url":        session.VNCURL,
 		"port":           session.Port,
+		"limits":         session.Limits,
 	})
 }
 
-func GenerateSession(c *gin.Context) {
+// sessionRequest is an optional body of session start
+type sessionRequest struct {
+	AssignmentID string `json:"assignment_id" binding:"omitempty,uuid"`
+	Profile      string `json:"profile" binding:"omitempty,max=64"`
+}
+
+func (h *Handlers) GenerateSession(c *gin.Context) {
 	var (
-		db   = models.GetDB()
-		data struct {
-			Surname string `json:"surname" binding:"required"`
-			Class   string `json:"class" binding:"required"`
-		}
-		user models.User
+		data sessionRequest
+		user = currentUser(c)
 	)
 
-	if err := c.ShouldBindJSON(&data); err != nil {
+	// Тело запроса необязательно
+	if err := c.ShouldBindJSON(&data); err != nil && !errors.Is(err, io.EOF) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if db.Preload("Session").First(&user, "surname = ? AND class = ?", data.Surname, data.Class).RowsAffected == 0 {
-		c.JSON(http.StatusConflict, gin.H{"error": "user with this class and surname not found"})
+	opts, ok := sessionOptions(c, data)
+	if !ok {
 		return
 	}
 
-	if user.Session == nil {
-		availablePort := tools.GetEmptyPort()
-		containerID := runGnuContainer(strconv.Itoa(availablePort))
+	session, err := supervisor.Open(c, h.rt, user, opts)
+	if err != nil {
+		status, message := openError(err)
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
 
-		user.Session = &models.Session{
-			Port:          uint(availablePort),
-			ContainerID:   containerID,
-			ConnectionURL: fmt.Sprintf("vnc://vuc@%s:%d", viper.GetString("external_host"), availablePort),
-		}
-		db.Save(&user)
+	password, err := session.Password()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't decrypt session password"})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"connection_url": user.Session.ConnectionURL,
+		"connection_url": session.ConnectionURL,
+		"vnc_url":        session.VNCURL,
+		"vnc_password":   password,
 		"surname":        user.Surname,
-		"class":          user.Class,
-		"session_id":     user.Session.ID,
+		"class":          user.ClassName(),
+		"session_id":     session.ID,
+		"assignment_id":  session.AssignmentID,
+		"profile":        session.Profile,
+		"expires_at":     session.ExpiresAt,
 	})
 }
 
-func runGnuContainer(port string) (containerID string) {
-	ctx := context.Background()
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		panic(err)
+func (h *Handlers) StopAndDeleteSession(c *gin.Context) {
+	session, ok := ownedSession(c)
+	if !ok {
+		return
 	}
 
-	exposedPorts, portBindings, err := nat.ParsePortSpecs([]string{port + ":5900"})
-	if err != nil {
-		panic(err)
+	reclaimed, err := supervisor.Close(c, h.rt, &session, models.CloseByUser)
+	switch {
+	case errors.Is(err, containers.ErrUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "container runtime is unreachable, session is kept"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't close session"})
+		return
+	case !reclaimed:
+		c.JSON(http.StatusGone, gin.H{"status": "session deleted, container was already gone"})
+		return
 	}
 
-	resp, err := cli.ContainerCreate(ctx, &container.Config{
-		Image:        viper.GetString("registry.image"),
-		ExposedPorts: exposedPorts,
-	}, &container.HostConfig{
-		PortBindings: portBindings,
-	}, nil, nil, "")
-	if err != nil {
-		panic(err)
+	c.Status(http.StatusOK)
+}
+
+// sessionOptions resolves assignment and image profile of session request,
+// writes an error response if they are not found or assignment is closed
+func sessionOptions(c *gin.Context, data sessionRequest) (opts supervisor.Options, ok bool) {
+	db := models.GetDB()
+
+	if data.AssignmentID != "" {
+		var assignment models.Assignment
+		if db.First(&assignment, "id = ?", data.AssignmentID).RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "assignment with this id not found"})
+			return opts, false
+		}
+		if assignment.Deadline != nil && time.Now().After(*assignment.Deadline) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "assignment deadline has passed"})
+			return opts, false
+		}
+		opts.Assignment = &assignment
+	}
+
+	if data.Profile != "" {
+		profile, ok := supervisor.FindProfile(data.Profile)
+		if !ok {
+			c.JSON(http.StatusConflict, gin.H{"error": "image profile with this name not found"})
+			return opts, false
+		}
+		opts.Profile = profile
+	}
+
+	return opts, true
+}
+
+// openError returns response status and message of session start error
+func openError(err error) (status int, message string) {
+	switch {
+	case errors.Is(err, supervisor.ErrUnknownProfile):
+		return http.StatusConflict, "image profile of session not found"
+	case errors.Is(err, supervisor.ErrImageNotReady):
+		return http.StatusServiceUnavailable, "image not ready, try later"
+	case errors.Is(err, models.ErrPortsExhausted):
+		return http.StatusServiceUnavailable, "no capacity for new sessions, try later"
+	case errors.Is(err, containers.ErrUnavailable):
+		return http.StatusServiceUnavailable, "container runtime is unreachable"
+	}
+	return http.StatusInternalServerError, "can't start session"
+}
+
+// ownedSession finds the session from uri, writes an error response if session
+// is not found or belongs to another user than authorized one
+func ownedSession(c *gin.Context) (session models.Session, ok bool) {
+	var (
+		db   = models.GetDB()
+		user = currentUser(c)
+		uri  struct {
+			ID string `uri:"id" binding:"required,uuid"`
+		}
+	)
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return session, false
+	}
+
+	if db.First(&session, "id = ?", uri.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "session with this id not found"})
+		return session, false
 	}
 
-	if err := cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
-		panic(err)
+	if user == nil || session.UserID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "session belongs to another user"})
+		return session, false
 	}
 
-	return resp.ID
+	return session, true
 }
 
-func StopAndDeleteSession(c *gin.Context) {
-	c.Status(http.StatusNotImplemented)
+// tokenSession finds the session from uri by its access token from query, writes
+// an error response if session is not found or token is wrong. Used where JWT
+// can't be sent: browsers don't allow headers on websocket connections.
+func tokenSession(c *gin.Context) (session models.Session, ok bool) {
+	var (
+		db  = models.GetDB()
+		uri struct {
+			ID string `uri:"id" binding:"required,uuid"`
+		}
+		query struct {
+			Token string `form:"token" binding:"required"`
+		}
+	)
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return session, false
+	}
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return session, false
+	}
+
+	if db.First(&session, "id = ?", uri.ID).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "session with this id not found"})
+		return session, false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(session.AccessToken), []byte(query.Token)) != 1 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "wrong session token"})
+		return session, false
+	}
+
+	return session, true
 }
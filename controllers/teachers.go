@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"gradio/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func GetTeacherClasses(c *gin.Context) {
+	var (
+		db   = models.GetDB()
+		user models.User
+		data struct {
+			ID string `uri:"id" binding:"required,uuid"`
+		}
+	)
+
+	if err := c.ShouldBindUri(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.First(&user, "id = ? AND rights = ?", data.ID, models.RightsTeacher).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "teacher with this id not found"})
+		return
+	}
+
+	classes, err := user.Classes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't load teacher classes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"classes": classes})
+}
+
+// SetTeacherClasses replaces the list of classes assigned to teacher
+func SetTeacherClasses(c *gin.Context) {
+	var (
+		db   = models.GetDB()
+		user models.User
+		uri  struct {
+			ID string `uri:"id" binding:"required,uuid"`
+		}
+		data struct {
+			Classes []string `json:"classes" binding:"required,dive,uuid"`
+		}
+	)
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.First(&user, "id = ? AND rights = ?", uri.ID, models.RightsTeacher).RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "teacher with this id not found"})
+		return
+	}
+
+	var classes []models.Class
+	if err := db.Find(&classes, "id IN ?", data.Classes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't load classes"})
+		return
+	}
+	for _, id := range data.Classes {
+		found := false
+		for _, class := range classes {
+			found = found || class.ID == id
+		}
+		if !found {
+			c.JSON(http.StatusConflict, gin.H{"error": "class " + id + " not found"})
+			return
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM class_teachers WHERE user_id = ?", user.ID).Error; err != nil {
+			return err
+		}
+		for _, class := range classes {
+			if err := tx.Exec("INSERT INTO class_teachers (class_id, user_id) VALUES (?, ?)", class.ID, user.ID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't save teacher classes"})
+		return
+	}
+
+	classes, _ = user.Classes()
+	c.JSON(http.StatusOK, gin.H{"classes": classes})
+}
@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"errors"
+	"gradio/models"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// RegisterValidators adds custom binding tags: ulogin for user logins and upwd for user passwords
+func RegisterValidators() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("unsupported binding validator")
+	}
+
+	if err := v.RegisterValidation("ulogin", func(fl validator.FieldLevel) bool {
+		return models.ValidLogin(fl.Field().String())
+	}); err != nil {
+		return err
+	}
+
+	return v.RegisterValidation("upwd", func(fl validator.FieldLevel) bool {
+		return models.ValidPassword(fl.Field().String())
+	})
+}
@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+func TestRegisterValidators(t *testing.T) {
+	if err := RegisterValidators(); err != nil {
+		t.Fatal(err)
+	}
+
+	type form struct {
+		Login    string `binding:"required,ulogin"`
+		Password string `binding:"required,upwd"`
+	}
+	tests := []struct {
+		name  string
+		form  form
+		valid bool
+	}{
+		{"valid", form{"ivanov.11a", "secret1"}, true},
+		{"bad login", form{"ivan ov", "secret1"}, false},
+		{"short password", form{"ivanov", "abc"}, false},
+		{"password with space", form{"ivanov", "sec ret1"}, false},
+		{"empty password", form{"ivanov", ""}, false},
+	}
+
+	for _, tt := range tests {
+		if err := binding.Validator.ValidateStruct(tt.form); (err == nil) != tt.valid {
+			t.Errorf("%s: validation error %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"errors"
+	"gradio/containers"
+	"gradio/web"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/websocket"
+)
+
+func VNCPage(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", web.VNCPage)
+}
+
+// NoVNC serves files of embedded noVNC client library
+func NoVNC(c *gin.Context) {
+	c.FileFromFS(path.Join("novnc", path.Clean("/"+c.Param("filepath"))), http.FS(web.NoVNC))
+}
+
+// ProxyVNC bridges websocket of noVNC client with VNC server of session container
+func (h *Handlers) ProxyVNC(c *gin.Context) {
+	session, ok := tokenSession(c)
+	if !ok {
+		return
+	}
+
+	info, err := h.rt.Inspect(c, session.ContainerID)
+	switch {
+	case errors.Is(err, containers.ErrUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "container runtime is unreachable"})
+		return
+	case err != nil || !info.Running || info.IP == "":
+		c.JSON(http.StatusConflict, gin.H{"error": "session container is not running"})
+		return
+	}
+
+	backend, err := net.DialTimeout("tcp", net.JoinHostPort(info.IP, strconv.Itoa(session.VNCPort)), 5*time.Second)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "can't connect to session vnc server"})
+		return
+	}
+	defer backend.Close()
+
+	if err := session.Touch(); err != nil {
+		log.WithError(err).WithField("session", session.ID).Warn("Can't update session activity")
+	}
+
+	websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			// noVNC просит подпротокол binary
+			for _, protocol := range config.Protocol {
+				if protocol == "binary" {
+					config.Protocol = []string{protocol}
+					return nil
+				}
+			}
+			config.Protocol = nil
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			ws.PayloadType = websocket.BinaryFrame
+
+			done := make(chan struct{}, 2)
+			go func() {
+				io.Copy(backend, ws)
+				done <- struct{}{}
+			}()
+			go func() {
+				io.Copy(ws, backend)
+				done <- struct{}{}
+			}()
+			<-done
+		},
+	}.ServeHTTP(c.Writer, c.Request)
+}
@@ -1,20 +1,16 @@
 package main
 
 import (
-	"context"
-	"encoding/base64"
-	"encoding/json"
 	"gradio/config"
+	"gradio/containers"
 	"gradio/controllers"
 	"gradio/middleware"
 	"gradio/models"
-	"io"
+	"gradio/supervisor"
+	"gradio/web"
 	"net"
 	"net/http"
-	"os"
 
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/client"
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
 
@@ -27,38 +23,129 @@ func main() {
 	config.Init()
 	config.Watch()
 
+	if err := web.CheckNoVNC(); err != nil {
+		log.WithError(err).Fatal("Can't serve VNC page")
+	}
+
+	if err := controllers.RegisterValidators(); err != nil {
+		log.WithError(err).Fatal("Can't register validators")
+	}
+
+	auth, err := newJWT()
+	if err != nil {
+		log.WithError(err).Fatal("JWT Error!")
+	}
+
 	r := gin.Default()
 	r.Use(middleware.AllowCORSConfig())
 	models.NewDBConnection()
-	pullGnuImage()
+
+	runtime, err := containers.NewDocker()
+	if err != nil {
+		log.WithError(err).Fatal("Can't create docker client")
+	}
+	h := controllers.NewHandlers(runtime)
+	if err := supervisor.SeedProfiles(); err != nil {
+		log.WithError(err).Fatal("Can't save image profiles")
+	}
+	if err := supervisor.MigrateClassLimits(); err != nil {
+		log.WithError(err).Fatal("Can't move class limits to database")
+	}
+	supervisor.PullProfiles(runtime)
+	supervisor.StartReconciler(runtime)
+	supervisor.StartReaper(runtime)
 
 	r.GET("ping", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "pong"}) })
+	r.GET("vnc", controllers.VNCPage)
+	r.GET("novnc/*filepath", controllers.NoVNC)
+
+	r.POST("login", auth.LoginHandler)
+	r.GET("refresh", auth.RefreshHandler)
+	r.POST("logout", auth.LogoutHandler)
 
 	// Роуты сессий студентов
-	session := r.Group("session")
+	// VNC websocket авторизуется токеном сессии из connection_url, браузер не передает JWT заголовок
+	r.GET("session/:id/vnc", h.ProxyVNC)
+
+	session := r.Group("session", auth.MiddlewareFunc())
+	{
+		session.POST("", h.GenerateSession)
+		session.GET(":id", h.GetStatusOfSession)
+		session.DELETE(":id", h.StopAndDeleteSession)
+		session.GET(":id/files", h.ListFiles)
+		session.GET(":id/files/download", h.DownloadFile)
+		session.GET(":id/files/archive", h.DownloadArchive)
+		session.POST(":id/files", h.UploadFile)
+	}
+
+	// Каталог лабораторных работ доступен всем пользователям для выбора assignment_id
+	assignments := r.Group("assignments", auth.MiddlewareFunc())
 	{
-		session.POST("", controllers.GenerateSession)
-		session.GET(":id", controllers.GetStatusOfSession)
-		session.DELETE(":id", controllers.StopAndDeleteSession)
+		assignments.GET("", controllers.GetAssignments)
+		assignments.GET(":id", controllers.GetAssignment)
 	}
 
-	admin := r.Group("admin")
+	admin := r.Group("admin", auth.MiddlewareFunc())
 	{
+		var (
+			manageUsers       = middleware.Require(models.PermUsers)
+			manageGrades      = middleware.Require(models.PermGrades)
+			manageSessions    = middleware.Require(models.PermSessions)
+			manageAssignments = middleware.Require(models.PermAssignments)
+			manageImages      = middleware.Require(models.PermImages)
+			manageStaff       = middleware.Require(models.PermStaff)
+			manageClasses     = middleware.Require(models.PermClasses)
+		)
+
 		// Управление студентами
 		users := admin.Group("users")
-		users.GET("", controllers.GetUsers)
-		users.GET(":id", controllers.NotImplemented)
-		users.POST("", controllers.AddUser)
-		users.PUT(":id", controllers.NotImplemented)
-		users.DELETE(":id", controllers.DelStudent)
+		users.GET("", manageUsers, h.GetUsers)
+		users.POST("import", manageUsers, controllers.ImportUsers)
+		users.GET("export", manageUsers, controllers.ExportUsers)
+		users.POST("export", manageUsers, controllers.ExportUsersPasswords)
+		users.GET(":id", manageUsers, controllers.GetUser)
+		users.POST("", manageUsers, controllers.AddUser)
+		users.PUT(":id", manageUsers, controllers.UpdateUser)
+		users.DELETE(":id", manageUsers, h.DelStudent)
 		// Управление оценками студентов
-		users.GET(":id/grades", controllers.NotImplemented)
-		users.POST(":id/grades", controllers.NotImplemented)
-		users.PUT(":id/grades/:grade_id", controllers.NotImplemented)
-		users.DELETE(":id/grades/:grade_id", controllers.NotImplemented)
+		users.GET(":id/grades", manageGrades, controllers.GetGrades)
+		users.POST(":id/grades", manageGrades, controllers.AddGrade)
+		users.PUT(":id/grades/:grade_id", manageGrades, controllers.UpdateGrade)
+		users.DELETE(":id/grades/:grade_id", manageGrades, controllers.DelGrade)
 		// Управление сессиями студентов
-		users.POST(":id/session", controllers.NotImplemented)
-		users.DELETE(":id/session", controllers.CloseSession)
+		users.POST(":id/session", manageSessions, h.StartSession)
+		users.DELETE(":id/session", manageSessions, h.CloseSession)
+		users.POST(":id/session/password", manageSessions, h.RotateSessionPassword)
+		// Классы учителя
+		users.GET(":id/classes", manageStaff, controllers.GetTeacherClasses)
+		users.PUT(":id/classes", manageStaff, controllers.SetTeacherClasses)
+
+		// Управление классами, учитель видит только свои классы
+		classes := admin.Group("classes")
+		classes.GET("", manageUsers, controllers.GetClasses)
+		classes.GET(":id", manageUsers, controllers.GetClass)
+		classes.GET(":id/students", manageUsers, h.GetClassStudents)
+		classes.POST(":id/sessions", manageSessions, h.StartClassSessions)
+		classes.POST("", manageClasses, controllers.AddClass)
+		classes.PUT(":id", manageClasses, controllers.UpdateClass)
+		classes.DELETE(":id", manageClasses, controllers.DelClass)
+
+		// Управление лабораторными работами
+		manage := admin.Group("assignments", manageAssignments)
+		manage.GET("", controllers.GetAssignments)
+		manage.GET(":id", controllers.GetAssignment)
+		manage.POST("", controllers.AddAssignment)
+		manage.PUT(":id", controllers.UpdateAssignment)
+		manage.DELETE(":id", controllers.DelAssignment)
+		manage.PUT(":id/starter", controllers.UploadStarter)
+
+		// Управление профилями образов
+		images := admin.Group("images", manageImages)
+		images.GET("", controllers.GetImages)
+		images.POST("", h.AddImage)
+		images.DELETE(":id", controllers.DelImage)
+		images.GET(":id/pull", h.GetImagePull)
+		images.POST(":id/pull", h.PullImage)
 	}
 
 	if _, err := net.Dial("tcp", "localhost:"+viper.GetString("listen_port")); err == nil {
@@ -70,30 +157,3 @@ func main() {
 		log.WithError(err).Fatal("AAAA Panic, Server 1$ D0wn.... jco8*")
 	}
 }
-
-func pullGnuImage() {
-	ctx := context.Background()
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		panic(err)
-	}
-
-	authConfig := types.AuthConfig{
-		Username: viper.GetString("registry.user"),
-		Password: viper.GetString("registry.password"),
-	}
-
-	encodedJSON, err := json.Marshal(authConfig)
-	if err != nil {
-		panic(err)
-	}
-	authStr := base64.URLEncoding.EncodeToString(encodedJSON)
-
-	out, err := cli.ImagePull(ctx, viper.GetString("registry.image"), types.ImagePullOptions{
-		RegistryAuth: authStr,
-	})
-	if err != nil {
-		panic(err)
-	}
-	io.Copy(os.Stdout, out)
-}
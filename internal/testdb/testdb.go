@@ -0,0 +1,95 @@
+// Package testdb connects tests to a disposable postgres database
+package testdb
+
+import (
+	"fmt"
+	"gradio/models"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+var connect sync.Once
+
+// Open connects models to database gradio_test_<name> on GRADIO_TEST_DB_HOST
+// and empties all its tables, test is skipped if the variable is not set.
+// Database is created if it doesn't exist. Each test package uses its own name,
+// because packages are tested in parallel.
+//
+// Optional GRADIO_TEST_DB_PORT, GRADIO_TEST_DB_USER and GRADIO_TEST_DB_PASSWORD
+// default to 5432, postgres and password.
+func Open(t testing.TB, name string) {
+	t.Helper()
+
+	host := os.Getenv("GRADIO_TEST_DB_HOST")
+	if host == "" {
+		t.Skip("GRADIO_TEST_DB_HOST is not set")
+	}
+
+	var err error
+	connect.Do(func() {
+		viper.Set("database.host", host)
+		viper.Set("database.port", env("GRADIO_TEST_DB_PORT", "5432"))
+		viper.Set("database.user", env("GRADIO_TEST_DB_USER", "postgres"))
+		viper.Set("database.password", env("GRADIO_TEST_DB_PASSWORD", "password"))
+		viper.Set("database.db_name", "gradio_test_"+name)
+		viper.Set("database.sslmode", "disable")
+
+		if err = createDatabase("gradio_test_" + name); err == nil {
+			models.NewDBConnection()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if models.GetDB() == nil {
+		t.Fatal("test database is not connected")
+	}
+
+	var tables []string
+	if err := models.GetDB().Raw("SELECT tablename FROM pg_tables WHERE schemaname = 'public'").Scan(&tables).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range tables {
+		if err := models.GetDB().Exec(fmt.Sprintf("TRUNCATE %q CASCADE", table)).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// createDatabase creates database if it doesn't exist
+func createDatabase(name string) error {
+	dsn := fmt.Sprintf("host=%v port=%v user=%v dbname=postgres password=%v sslmode=disable",
+		viper.GetString("database.host"),
+		viper.GetString("database.port"),
+		viper.GetString("database.user"),
+		viper.GetString("database.password"),
+	)
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		return err
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	var count int64
+	if err := db.Raw("SELECT count(*) FROM pg_database WHERE datname = ?", name).Scan(&count).Error; err != nil {
+		return err
+	}
+	if count != 0 {
+		return nil
+	}
+	return db.Exec(fmt.Sprintf("CREATE DATABASE %q", name)).Error
+}
+
+func env(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -1,9 +1,10 @@
 package main
 
 import (
-	"strings"
+	"net/http"
 	"time"
 
+	"gradio/config"
 	"gradio/models"
 
 	jwt "github.com/appleboy/gin-jwt/v2"
@@ -13,12 +14,13 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-// JWT is used to pass through jwt
-var JWT = func() *jwt.GinJWTMiddleware {
-	authMiddleware, err := jwt.New(&jwt.GinJWTMiddleware{
-		Key:        []byte(viper.GetString("JWT_SECRET")),
-		MaxRefresh: 720 * time.Hour,
-		Timeout:    time.Hour,
+// newJWT creates middleware that signs tokens with jwt.secret or JWT_SECRET, it must be
+// called after config is loaded and validated
+func newJWT() (*jwt.GinJWTMiddleware, error) {
+	return jwt.New(&jwt.GinJWTMiddleware{
+		Key:        []byte(config.Secret("jwt.secret", config.JWTSecretEnv)),
+		MaxRefresh: viper.GetDuration("jwt.max_refresh"),
+		Timeout:    viper.GetDuration("jwt.timeout"),
 		PayloadFunc: func(data interface{}) jwt.MapClaims {
 			if u, ok := data.(models.User); ok {
 				return jwt.MapClaims{
@@ -29,13 +31,21 @@ var JWT = func() *jwt.GinJWTMiddleware {
 		},
 		IdentityHandler: func(c *gin.Context) interface{} {
 			claims := jwt.ExtractClaims(c)
+			id, ok := claims["id"].(string)
+			if !ok {
+				return nil
+			}
 			user := models.User{}
-			user.ID = claims["id"].(string)
+			user.ID = id
 			return &user
 		},
 		Authenticator: authenticate,
 		Authorizator:  authorizate,
 		Unauthorized: func(c *gin.Context, code int, message string) {
+			// Подписанный токен без id пользователя не аутентифицирует, а не запрещает
+			if _, ok := c.Get(jwt.IdentityKey); code == http.StatusForbidden && !ok {
+				code, message = http.StatusUnauthorized, "token has no user id"
+			}
 			c.JSON(code, gin.H{"error": message})
 		},
 		LoginResponse: func(c *gin.Context, code int, token string, expire time.Time) {
@@ -54,21 +64,19 @@ var JWT = func() *jwt.GinJWTMiddleware {
 			})
 		},
 	})
-	if err != nil {
-		log.WithError(err).Fatal("JWT Error!")
-	}
-
-	return authMiddleware
-}()
+}
 
-// authorizate user authorization handler
+// authorizate user authorization handler, loads user for permission checks of routes
 func authorizate(data interface{}, c *gin.Context) bool {
-	user := data.(*models.User)
+	user, ok := data.(*models.User)
+	if !ok {
+		return false
+	}
 	if err := user.Get(user.ID); err != nil {
 		log.WithError(err).Warn("Can't authorize user")
 		return false
 	}
-	return true
+	return models.ValidRights(user.Rights)
 }
 
 // authenticate user authentication handler
@@ -77,7 +85,7 @@ func authenticate(c *gin.Context) (interface{}, error) {
 		db       = models.GetDB()
 		user     models.User
 		authData struct {
-			Login    string `json:"login" binding:"required,email"`
+			Login    string `json:"login" binding:"required,max=128"`
 			Password string `json:"password" binding:"required,upwd"`
 		}
 	)
@@ -86,8 +94,7 @@ func authenticate(c *gin.Context) (interface{}, error) {
 		return "", err
 	}
 
-	lowerLogin := strings.ToLower(authData.Login)
-	if db.First(&user, "Surname = ?", lowerLogin).RowsAffected == 0 {
+	if db.First(&user, "login = ?", models.NormalizeLogin(authData.Login)).RowsAffected == 0 {
 		return "", jwt.ErrFailedAuthentication
 	}
 
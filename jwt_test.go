@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"gradio/controllers"
+	"gradio/internal/testdb"
+	"gradio/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// setupAuth connects test database, creates a student with password secret1
+// and returns router with authentication routes
+func setupAuth(t *testing.T) *gin.Engine {
+	t.Helper()
+	testdb.Open(t, "main")
+
+	viper.Set("jwt.secret", "test")
+	viper.Set("jwt.timeout", time.Hour)
+	viper.Set("jwt.max_refresh", 24*time.Hour)
+	if err := controllers.RegisterValidators(); err != nil {
+		t.Fatal(err)
+	}
+
+	user := models.User{Surname: "Ivanov", Login: "Ivanov.11a", Rights: models.RightsStudent}
+	if err := user.GenHash("secret1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.GetDB().Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	auth, err := newJWT()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("login", auth.LoginHandler)
+	r.GET("refresh", auth.RefreshHandler)
+	r.POST("logout", auth.LogoutHandler)
+	return r
+}
+
+// serve performs request and returns response with decoded token, if any
+func serve(r *gin.Engine, method, path, body, token string) (*httptest.ResponseRecorder, string) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w, resp.Token
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"exact login", `{"login": "ivanov.11a", "password": "secret1"}`, http.StatusOK},
+		{"upper case login", `{"login": "IVANOV.11A", "password": "secret1"}`, http.StatusOK},
+		{"login with spaces", `{"login": " Ivanov.11a ", "password": "secret1"}`, http.StatusOK},
+		{"wrong password", `{"login": "ivanov.11a", "password": "secret2"}`, http.StatusUnauthorized},
+		{"unknown login", `{"login": "petrov.11a", "password": "secret1"}`, http.StatusUnauthorized},
+		{"invalid password", `{"login": "ivanov.11a", "password": "abc"}`, http.StatusUnauthorized},
+		{"no login", `{"password": "secret1"}`, http.StatusUnauthorized},
+	}
+
+	r := setupAuth(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, token := serve(r, http.MethodPost, "/login", tt.body, "")
+			if w.Code != tt.code {
+				t.Fatalf("code = %d, want %d: %s", w.Code, tt.code, w.Body)
+			}
+			if (token != "") != (tt.code == http.StatusOK) {
+				t.Errorf("unexpected token %q", token)
+			}
+		})
+	}
+}
+
+func TestRefreshAndLogout(t *testing.T) {
+	r := setupAuth(t)
+
+	w, token := serve(r, http.MethodPost, "/login", `{"login": "ivanov.11a", "password": "secret1"}`, "")
+	if w.Code != http.StatusOK || token == "" {
+		t.Fatalf("login code = %d: %s", w.Code, w.Body)
+	}
+
+	if w, _ := serve(r, http.MethodGet, "/refresh", "", "bad token"); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh with bad token code = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	w, refreshed := serve(r, http.MethodGet, "/refresh", "", token)
+	if w.Code != http.StatusOK || refreshed == "" {
+		t.Fatalf("refresh code = %d: %s", w.Code, w.Body)
+	}
+
+	if w, _ := serve(r, http.MethodPost, "/logout", "", refreshed); w.Code != http.StatusOK {
+		t.Errorf("logout code = %d, want %d", w.Code, http.StatusOK)
+	}
+}
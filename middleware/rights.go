@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"gradio/models"
+	"net/http"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// Require allows request only to users whose role has permission,
+// must be used after JWT middleware
+func Require(perm models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, _ := c.Get(jwt.IdentityKey)
+		if user, ok := identity.(*models.User); !ok || !user.Can(perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not enough rights"})
+			return
+		}
+		c.Next()
+	}
+}
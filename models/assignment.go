@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Assignment is a lab work with its own container image and starter files
+type Assignment struct {
+	Base
+	Title       string `json:"title" gorm:"size:256;not null"`
+	Description string `json:"description"`
+	// Profile is a name of image profile of the lab, default profile if empty
+	Profile string `json:"profile"`
+	// Starter is a zip archive extracted into student workspace on session start
+	Starter    []byte     `json:"-"`
+	HasStarter bool       `json:"has_starter" gorm:"-"`
+	Deadline   *time.Time `json:"deadline,omitempty"`
+	// TimeLimit is a session lifetime in minutes, zero is unlimited
+	TimeLimit int `json:"time_limit"`
+}
+
+// AfterFind sets starter files flag
+func (a *Assignment) AfterFind(tx *gorm.DB) error {
+	a.HasStarter = len(a.Starter) != 0
+	return nil
+}
+
+// FindAssignments loads assignments matching query without starter archives,
+// which can be large, HasStarter is checked by database
+func FindAssignments(query *gorm.DB, assignments *[]Assignment) error {
+	if err := query.Omit("starter").Find(assignments).Error; err != nil {
+		return err
+	}
+	if len(*assignments) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(*assignments))
+	for i, a := range *assignments {
+		ids[i] = a.ID
+	}
+	var withStarter []string
+	if err := db.Model(&Assignment{}).Where("id IN ? AND length(starter) > 0", ids).
+		Pluck("id", &withStarter).Error; err != nil {
+		return err
+	}
+
+	has := make(map[string]bool, len(withStarter))
+	for _, id := range withStarter {
+		has[id] = true
+	}
+	for i := range *assignments {
+		(*assignments)[i].HasStarter = has[(*assignments)[i].ID]
+	}
+	return nil
+}
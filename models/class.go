@@ -0,0 +1,64 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// Class is a study group of students
+type Class struct {
+	Base
+	Name string `json:"name" gorm:"size:64;not null;uniqueIndex:idx_classes_name,where:deleted_at IS NULL"`
+	// Limits override resource limits of profile for students of class, they
+	// are stored with class, so renaming doesn't lose them
+	Limits   Limits `json:"limits" gorm:"embedded;embeddedPrefix:limit_"`
+	Students []User `json:"students,omitempty"`
+	Teachers []User `json:"teachers,omitempty" gorm:"many2many:class_teachers"`
+}
+
+// FindClass returns class by name
+func FindClass(name string) (*Class, bool) {
+	var class Class
+	if db.First(&class, "name = ?", name).RowsAffected == 0 {
+		return nil, false
+	}
+	return &class, true
+}
+
+// migrateClasses converts class names of users and teachers into classes table
+func migrateClasses() error {
+	if err := db.AutoMigrate(&User{}, &Class{}); err != nil {
+		return err
+	}
+
+	names := "SELECT class FROM users WHERE class <> ''"
+	if db.Migrator().HasTable("teacher_classes") {
+		names += " UNION SELECT class FROM teacher_classes"
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`INSERT INTO classes (name, created_at, updated_at)
+			SELECT DISTINCT n.class, now(), now() FROM (` + names + `) n
+			WHERE NOT EXISTS (SELECT 1 FROM classes c WHERE c.name = n.class AND c.deleted_at IS NULL)`).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec(`UPDATE users u SET class_id = c.id FROM classes c
+			WHERE c.name = u.class AND c.deleted_at IS NULL`).Error; err != nil {
+			return err
+		}
+
+		if tx.Migrator().HasTable("teacher_classes") {
+			if err := tx.Exec(`INSERT INTO class_teachers (class_id, user_id)
+				SELECT c.id, t.teacher_id FROM teacher_classes t
+				JOIN classes c ON c.name = t.class AND c.deleted_at IS NULL
+				ON CONFLICT DO NOTHING`).Error; err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropTable("teacher_classes"); err != nil {
+				return err
+			}
+		}
+
+		return tx.Migrator().DropColumn(&User{}, "class")
+	})
+}
@@ -0,0 +1,13 @@
+package models
+
+// Grade is a student mark for graded work
+type Grade struct {
+	Base
+	UserID string `json:"user_id" gorm:"index"`
+	Mark   int    `json:"mark"`
+	// Assignment is a name of graded work, title of AssignmentID if it's set
+	Assignment   string  `json:"assignment" gorm:"size:256"`
+	AssignmentID *string `json:"assignment_id,omitempty" gorm:"type:uuid"`
+	Comment      string  `json:"comment"`
+	GraderID     *string `json:"grader_id,omitempty" gorm:"type:uuid"`
+}
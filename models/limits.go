@@ -0,0 +1,10 @@
+package models
+
+// Limits is a resource limits applied to session container
+type Limits struct {
+	CPUs float64 `json:"cpus,omitempty"`
+	// Memory in bytes
+	Memory int64  `json:"memory,omitempty"`
+	Pids   int64  `json:"pids,omitempty"`
+	Disk   string `json:"disk,omitempty"`
+}
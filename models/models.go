@@ -63,6 +63,32 @@ func NewDBConnection() {
 		&User{},
 		&Session{},
 		&Grade{},
+		&PortAllocation{},
+		&Assignment{},
+		&ImageProfile{},
+		&Class{},
+	}
+
+	// Задвоенные сессии не дадут создать уникальный индекс, оставляем последнюю
+	if db.Migrator().HasTable(&Session{}) {
+		if err := db.Exec(`UPDATE sessions SET deleted_at = now() WHERE id IN (
+			SELECT id FROM (
+				SELECT id, row_number() OVER (PARTITION BY user_id ORDER BY created_at DESC) AS n
+				FROM sessions WHERE deleted_at IS NULL AND user_id IS NOT NULL
+			) s WHERE n > 1)`).Error; err != nil {
+			log.WithError(err).Fatal("Can't close duplicated sessions")
+		}
+	}
+
+	// Логины существующих пользователей генерируются после миграции
+	needLogins := db.Migrator().HasTable(&User{}) && !db.Migrator().HasColumn(&User{}, "Login")
+
+	// Классы пользователей переносятся из строкового столбца в таблицу классов
+	if db.Migrator().HasTable(&User{}) && db.Migrator().HasColumn(&User{}, "class") {
+		log.Info("Migrating user classes...")
+		if err := migrateClasses(); err != nil {
+			log.WithError(err).Fatal("Can't migrate user classes")
+		}
 	}
 
 	log.WithField("models", modelsNames(models2Migrate...)).Info("Migrating models...")
@@ -71,6 +97,12 @@ func NewDBConnection() {
 	}
 	log.Info("Models migrated...")
 
+	if needLogins {
+		if err := backfillLogins(); err != nil {
+			log.WithError(err).Fatal("Can't generate user logins")
+		}
+	}
+
 	var usersCount int64
 	if db.Model(&User{}).Count(&usersCount); usersCount <= 0 {
 		log.Info("Not found any users...")
@@ -78,8 +110,8 @@ func NewDBConnection() {
 
 		admin := &User{
 			Surname: "admin",
-			Rights:  "admin",
-			Class:   "superclass",
+			Login:   "admin",
+			Rights:  RightsAdmin,
 		}
 
 		if err := admin.GenHash("admin"); err != nil {
@@ -92,6 +124,25 @@ func NewDBConnection() {
 	}
 }
 
+// backfillLogins generates logins of all users from surname and class
+func backfillLogins() error {
+	var users []User
+	if err := db.Unscoped().Preload("Class").Order("created_at").Find(&users).Error; err != nil {
+		return err
+	}
+	for i := range users {
+		if err := users[i].GenLogin(db); err != nil {
+			return err
+		}
+		if err := db.Unscoped().Model(&users[i]).UpdateColumn("login", users[i].Login).Error; err != nil {
+			return err
+		}
+	}
+
+	log.WithField("users", len(users)).Info("User logins generated")
+	return nil
+}
+
 func modelsNames(v ...interface{}) (names []string) {
 	for _, model := range v {
 		names = append(names, reflect.TypeOf(model).Elem().Name())
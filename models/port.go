@@ -0,0 +1,53 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ErrPortsExhausted is returned when all ports of configured range are allocated
+var ErrPortsExhausted = errors.New("no free ports left")
+
+// allocateAttempts is a number of tries to win a port from concurrent allocations
+const allocateAttempts = 10
+
+// PortAllocation is a host port reserved for session container
+type PortAllocation struct {
+	Port      uint `gorm:"primarykey;autoIncrement:false"`
+	CreatedAt time.Time
+}
+
+// AllocatePort reserves the first free port of configured range
+func AllocatePort() (uint, error) {
+	for i := 0; i < allocateAttempts; i++ {
+		var port uint
+		// Конкурентный запрос может занять тот же порт, тогда вставка ничего не вернет
+		result := db.Raw(`INSERT INTO port_allocations (port, created_at)
+			SELECT p, now() FROM generate_series(?::int, ?::int) AS p
+			WHERE p NOT IN (SELECT port FROM port_allocations)
+			ORDER BY p LIMIT 1
+			ON CONFLICT DO NOTHING
+			RETURNING port`, viper.GetInt("ports.min"), viper.GetInt("ports.max")).Scan(&port)
+		if result.Error != nil {
+			return 0, result.Error
+		}
+		if result.RowsAffected != 0 {
+			return port, nil
+		}
+	}
+	return 0, ErrPortsExhausted
+}
+
+// ReleasePort returns port to the pool
+func ReleasePort(port uint) error {
+	return db.Delete(&PortAllocation{}, "port = ?", port).Error
+}
+
+// ReleaseStalePorts releases ports allocated before t that are not used by any session
+func ReleaseStalePorts(t time.Time) (int64, error) {
+	result := db.Where("created_at < ? AND port NOT IN (?)", t,
+		db.Model(&Session{}).Select("port")).Delete(&PortAllocation{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultProfile is a name of the image profile from registry config
+const DefaultProfile = "default"
+
+// ImageProfile is a named container image of lab desktop with its settings
+type ImageProfile struct {
+	Base
+	Name  string `json:"name" gorm:"size:64;not null;uniqueIndex:idx_image_profiles_name,where:deleted_at IS NULL"`
+	Image string `json:"image" gorm:"not null"`
+	// RegistryUser and RegistryPassword are credentials to pull image
+	RegistryUser     string  `json:"registry_user,omitempty"`
+	RegistryPassword string  `json:"-"` // Зашифрован ключом vnc.secret
+	VNCPort          int     `json:"vnc_port" gorm:"default:5900"`
+	Env              Strings `json:"env" gorm:"type:text"`
+	// Limits are resource defaults of profile, class limits take precedence
+	Limits Limits `json:"limits" gorm:"embedded;embeddedPrefix:limit_"`
+}
+
+// SetRegistryPassword encrypts and sets registry password of the profile
+func (p *ImageProfile) SetRegistryPassword(password string) (err error) {
+	if password == "" {
+		p.RegistryPassword = ""
+		return nil
+	}
+	p.RegistryPassword, err = encrypt(password)
+	return
+}
+
+// RegistryAuth returns decrypted registry password
+func (p *ImageProfile) RegistryAuth() (string, error) {
+	if p.RegistryPassword == "" {
+		return "", nil
+	}
+	return decrypt(p.RegistryPassword)
+}
+
+// Strings is a list of strings stored as JSON
+type Strings []string
+
+// Value implements driver.Valuer
+func (s Strings) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(s)
+	return string(data), err
+}
+
+// Scan implements sql.Scanner
+func (s *Strings) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	case []byte:
+		return json.Unmarshal(v, s)
+	}
+	return fmt.Errorf("can't scan %T into Strings", value)
+}
@@ -0,0 +1,81 @@
+package models
+
+// Роли пользователей
+const (
+	RightsAdmin   = "admin"
+	RightsTeacher = "teacher"
+	RightsStudent = "student"
+)
+
+// Permission is an action on admin API allowed to some roles
+type Permission string
+
+const (
+	// PermUsers allows to list, create and delete students
+	PermUsers Permission = "users"
+	// PermGrades allows to manage student grades
+	PermGrades Permission = "grades"
+	// PermSessions allows to close student sessions and rotate their passwords
+	PermSessions Permission = "sessions"
+	// PermAssignments allows to manage assignment catalog
+	PermAssignments Permission = "assignments"
+	// PermImages allows to manage image profiles
+	PermImages Permission = "images"
+	// PermStaff allows to manage teachers, admins and classes of teachers
+	PermStaff Permission = "staff"
+	// PermClasses allows to create, rename and delete classes
+	PermClasses Permission = "classes"
+)
+
+// permissions is a permission matrix of roles, admin is allowed everything.
+// Catalogs of assignments and images are global, so only admins manage them.
+// Teachers are additionally restricted to students of their classes.
+var permissions = map[string][]Permission{
+	RightsTeacher: {PermUsers, PermGrades, PermSessions},
+	RightsStudent: {},
+}
+
+// ValidRights reports whether rights is a known role
+func ValidRights(rights string) bool {
+	_, ok := permissions[rights]
+	return ok || rights == RightsAdmin
+}
+
+// Can reports whether user role has permission
+func (u *User) Can(perm Permission) bool {
+	if u.Rights == RightsAdmin {
+		return true
+	}
+	for _, p := range permissions[u.Rights] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Classes returns classes assigned to teacher
+func (u *User) Classes() (classes []Class, err error) {
+	err = db.Joins("JOIN class_teachers ON class_teachers.class_id = classes.id").
+		Where("class_teachers.user_id = ?", u.ID).Order("name").Find(&classes).Error
+	return
+}
+
+// Teaches reports whether class is assigned to teacher
+func (u *User) Teaches(classID string) bool {
+	var count int64
+	db.Table("class_teachers").Where("user_id = ? AND class_id = ?", u.ID, classID).Count(&count)
+	return count != 0
+}
+
+// Manages reports whether user can manage target user: admins manage everybody,
+// teachers manage only students of their classes
+func (u *User) Manages(target *User) bool {
+	switch u.Rights {
+	case RightsAdmin:
+		return true
+	case RightsTeacher:
+		return target.Rights == RightsStudent && target.ClassID != nil && u.Teaches(*target.ClassID)
+	}
+	return false
+}
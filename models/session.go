@@ -0,0 +1,80 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Причины закрытия сессии
+const (
+	CloseByUser  = "user"
+	CloseByAdmin = "admin"
+	CloseIdle    = "idle"
+	CloseExpired = "lifetime"
+	CloseDead    = "dead"
+)
+
+type Session struct {
+	Base
+	UserID        string `json:"-" gorm:"uniqueIndex:idx_sessions_user_id,where:deleted_at IS NULL"`
+	Port          uint   `json:"-"`
+	ContainerID   string
+	ConnectionURL string `json:"connection_url"`
+	// VNCURL is a direct VNC address, set only when container ports are published
+	VNCURL       string     `json:"vnc_url,omitempty"`
+	AccessToken  string     `json:"-"`
+	VNCPassword  string     `json:"-"` // Зашифрован ключом vnc.secret
+	Limits       Limits     `json:"limits" gorm:"embedded;embeddedPrefix:limit_"`
+	AssignmentID *string    `json:"assignment_id,omitempty" gorm:"type:uuid"`
+	Profile      string     `json:"profile"`
+	VNCPort      int        `json:"-" gorm:"default:5900"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	LastSeenAt   *time.Time `json:"last_seen_at,omitempty"`
+	Prewarmed    bool       `json:"prewarmed,omitempty"` // Запущена заранее, простой считается с первого подключения
+	ClosedReason string     `json:"-"`
+}
+
+// BeforeCreate generates access token of the session
+func (s *Session) BeforeCreate(tx *gorm.DB) error {
+	if s.AccessToken != "" {
+		return nil
+	}
+	token := make([]byte, 24)
+	if _, err := rand.Read(token); err != nil {
+		return err
+	}
+	s.AccessToken = hex.EncodeToString(token)
+	return nil
+}
+
+// SetPassword encrypts and sets VNC password of the session
+func (s *Session) SetPassword(password string) (err error) {
+	s.VNCPassword, err = encrypt(password)
+	return
+}
+
+// Password returns decrypted VNC password, empty for sessions without password
+func (s *Session) Password() (string, error) {
+	if s.VNCPassword == "" {
+		return "", nil
+	}
+	return decrypt(s.VNCPassword)
+}
+
+// Touch marks session as active right now
+func (s *Session) Touch() error {
+	now := time.Now()
+	s.LastSeenAt = &now
+	return db.Model(s).UpdateColumn("last_seen_at", now).Error
+}
+
+// LastSeen returns time of last session activity
+func (s *Session) LastSeen() time.Time {
+	if s.LastSeenAt == nil {
+		return s.CreatedAt
+	}
+	return *s.LastSeenAt
+}
@@ -2,62 +2,139 @@ package models
 
 import (
 	"fmt"
-	"math/rand"
+	"regexp"
 	"strings"
-	"time"
+	"unicode"
 
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+// loginChars matches logins made of letters, digits, dots, dashes and underscores
+var loginChars = regexp.MustCompile(`^[\p{L}\p{N}._-]+$`)
+
+// notLoginChars matches characters that can't be used in login
+var notLoginChars = regexp.MustCompile(`[^\p{L}\p{N}._-]+`)
+
 // User is table of shop users
 type User struct {
 	Base
 	Rights    string   `json:"rights" gorm:"default:student"`
+	Login     string   `json:"login" gorm:"size:128;uniqueIndex:idx_users_login,where:deleted_at IS NULL"`
 	Surname   string   `json:"surname" gorm:"size:128;not null" `
-	Class     string   `json:"class" gorm:"not null"`
+	ClassID   *string  `json:"class_id,omitempty" gorm:"type:uuid"`
+	Class     *Class   `json:"class,omitempty"`
 	GivenName *string  `json:"given_name,omitempty" gorm:"size:128"`
 	Session   *Session `json:"session,omitempty"`
 	Grades    []Grade  `json:"grades,omitempty"`
 	Hash      string   `json:"-" gorm:"not null"`
+	Home      string   `json:"-"` // Docker том или каталог хоста с домашней папкой
 	Password  string   `json:"password,omitempty" gorm:"-"`
 }
 
 func (u *User) Get(id string) error {
-	if db.First(&u, "id = ?", id).RowsAffected == 0 {
+	if db.Preload("Class").First(&u, "id = ?", id).RowsAffected == 0 {
 		return fmt.Errorf("user not found")
 	}
 	return nil
 }
 
-// GenHash is generate password hash to this model
-func (u *User) GenHash(pass string) (err error) {
-	if pass == "" {
-		rand.Seed(time.Now().UnixNano())
-		chars := []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ" + "abcdefghijklmnopqrstuvwxyz" + "0123456789")
-		length := 8
-		var b strings.Builder
-		for i := 0; i < length; i++ {
-			b.WriteRune(chars[rand.Intn(len(chars))])
+// ClassName returns name of user class, empty if class is not set or not loaded
+func (u *User) ClassName() string {
+	if u.Class == nil {
+		return ""
+	}
+	return u.Class.Name
+}
+
+// BeforeSave keeps login in canonical form
+func (u *User) BeforeSave(tx *gorm.DB) error {
+	u.Login = NormalizeLogin(u.Login)
+	return nil
+}
+
+// NormalizeLogin returns login in canonical form, logins are case-insensitive
+func NormalizeLogin(login string) string {
+	return strings.ToLower(strings.TrimSpace(login))
+}
+
+// ValidLogin reports whether login can be set by admin
+func ValidLogin(login string) bool {
+	return len(login) <= 128 && loginChars.MatchString(login)
+}
+
+// ValidPassword reports whether password is acceptable: 4 to 72 bytes (bcrypt
+// limit) of printable characters without spaces
+func ValidPassword(password string) bool {
+	if len(password) < 4 || len(password) > 72 {
+		return false
+	}
+	for _, r := range password {
+		if !unicode.IsPrint(r) || unicode.IsSpace(r) {
+			return false
 		}
-		u.Password = b.String()
 	}
+	return true
+}
 
-	if hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost); err == nil {
-		u.Hash = string(hash)
+// LoginTaken reports whether login is used by another user, tx is a database
+// or a transaction to check in
+func LoginTaken(tx *gorm.DB, login, exceptID string) (bool, error) {
+	var count int64
+	query := tx.Model(&User{}).Where("login = ?", NormalizeLogin(login))
+	if exceptID != "" {
+		query = query.Where("id <> ?", exceptID)
 	}
+	err := query.Count(&count).Error
+	return count != 0, err
+}
 
-	return err
+// GenLogin sets unique login made from surname and class, e.g. ivanov.11a,
+// a number is appended if login is taken in tx. Class must be loaded.
+func (u *User) GenLogin(tx *gorm.DB) error {
+	base := u.loginBase()
+	login := base
+	for i := 2; ; i++ {
+		taken, err := LoginTaken(tx, login, u.ID)
+		if err != nil {
+			return err
+		}
+		if !taken {
+			u.Login = login
+			return nil
+		}
+		login = fmt.Sprintf("%s%d", base, i)
+	}
 }
 
-type Session struct {
-	Base
-	UserID        string `json:"-"`
-	Port          uint   `json:"-"`
-	ContainerID   string
-	ConnectionURL string `json:"connection_url"`
+// loginBase returns login made from surname and class, users without class,
+// e.g. teachers, get login from surname only
+func (u *User) loginBase() string {
+	var (
+		surname = notLoginChars.ReplaceAllString(u.Surname, "")
+		class   = notLoginChars.ReplaceAllString(u.ClassName(), "")
+	)
+	if class == "" {
+		return NormalizeLogin(surname)
+	}
+	return NormalizeLogin(surname + "." + class)
 }
 
-type Grade struct {
-	UserID string
-	Mark   int
+// GenHash is generate password hash to this model, if pass is empty a random
+// password is generated and returned in Password field
+func (u *User) GenHash(pass string) error {
+	if pass == "" {
+		generated, err := RandomPassword(8)
+		if err != nil {
+			return err
+		}
+		u.Password, pass = generated, generated
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	u.Hash = string(hash)
+	return nil
 }
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeLogin(t *testing.T) {
+	tests := []struct {
+		login string
+		want  string
+	}{
+		{"ivanov", "ivanov"},
+		{"Ivanov.11A", "ivanov.11a"},
+		{"  petrov_2 ", "petrov_2"},
+		{"СИДОРОВ", "сидоров"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeLogin(tt.login); got != tt.want {
+			t.Errorf("NormalizeLogin(%q) = %q, want %q", tt.login, got, tt.want)
+		}
+	}
+}
+
+func TestValidLogin(t *testing.T) {
+	tests := []struct {
+		login string
+		want  bool
+	}{
+		{"ivanov.11a", true},
+		{"petrov-2_b", true},
+		{"сидоров", true},
+		{"", false},
+		{"ivan ov", false},
+		{"ivanov@school", false},
+		{strings.Repeat("a", 128), true},
+		{strings.Repeat("a", 129), false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidLogin(tt.login); got != tt.want {
+			t.Errorf("ValidLogin(%q) = %v, want %v", tt.login, got, tt.want)
+		}
+	}
+}
+
+func TestValidPassword(t *testing.T) {
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"secret1", true},
+		{"P@$$w0rd!", true},
+		{"пароль", true},
+		{"abc", false},
+		{"abcd", true},
+		{"with space", false},
+		{"tab\tbed", false},
+		{"bell\a", false},
+		{strings.Repeat("a", 72), true},
+		{strings.Repeat("a", 73), false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidPassword(tt.password); got != tt.want {
+			t.Errorf("ValidPassword(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestLoginBase(t *testing.T) {
+	tests := []struct {
+		surname string
+		class   *Class
+		want    string
+	}{
+		{"Иванов", &Class{Name: "11А"}, "иванов.11а"},
+		{"Petrov", &Class{Name: "10 B"}, "petrov.10b"},
+		{"Петров", nil, "петров"},
+		{"Smith-Jones", &Class{Name: "#"}, "smith-jones"},
+		{"O'Brien", nil, "obrien"},
+	}
+
+	for _, tt := range tests {
+		user := User{Surname: tt.surname, Class: tt.class}
+		if got := user.loginBase(); got != tt.want {
+			t.Errorf("loginBase of %q in %+v = %q, want %q", tt.surname, tt.class, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"gradio/containers"
+	"gradio/models"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+// Home returns mount of the user home, on first call home is created as named
+// volume or as directory under workspace.root if it's set
+func Home(ctx context.Context, rt containers.Runtime, user *models.User) (containers.Mount, error) {
+	if user.Home == "" {
+		if root := viper.GetString("workspace.root"); root != "" {
+			user.Home = filepath.Join(root, user.ID)
+		} else {
+			user.Home = "gradio-home-" + user.ID
+		}
+		if err := models.GetDB().Model(user).Update("home", user.Home).Error; err != nil {
+			return containers.Mount{}, err
+		}
+	}
+
+	// Каталог хоста docker создаст сам при монтировании
+	if !filepath.IsAbs(user.Home) {
+		if err := rt.CreateVolume(ctx, user.Home, Labels(user.ID)); err != nil {
+			return containers.Mount{}, err
+		}
+	}
+
+	return containers.Mount{Source: user.Home, Target: viper.GetString("workspace.path")}, nil
+}
+
+// RemoveHome deletes the user home volume or directory
+func RemoveHome(ctx context.Context, rt containers.Runtime, user *models.User) error {
+	switch {
+	case user.Home == "":
+		return nil
+	case filepath.IsAbs(user.Home):
+		return os.RemoveAll(user.Home)
+	}
+
+	if err := rt.RemoveVolume(ctx, user.Home); err != nil && !errors.Is(err, containers.ErrNotFound) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gradio/config"
+	"gradio/containers"
+	"gradio/models"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Состояния загрузки образа
+const (
+	PullPending  = "pending"
+	PullRunning  = "pulling"
+	PullRetrying = "retrying"
+	PullReady    = "ready"
+	PullFailed   = "failed"
+)
+
+// PullState is a state of the last image pull
+type PullState struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Attempt is a number of the current or the last pull attempt
+	Attempt int `json:"attempt,omitempty"`
+	// NextRetry is a time of the next attempt after failed one
+	NextRetry *time.Time `json:"next_retry,omitempty"`
+	// Progress is a downloaded part of image in percent
+	Progress  float64                  `json:"progress"`
+	Layers    map[string]LayerProgress `json:"layers,omitempty"`
+	UpdatedAt time.Time                `json:"updated_at"`
+}
+
+// LayerProgress is a pull state of one image layer
+type LayerProgress struct {
+	Status  string `json:"status"`
+	Current int64  `json:"current,omitempty"`
+	Total   int64  `json:"total,omitempty"`
+}
+
+// downloaded returns downloaded part of layer from 0 to 1
+func (l LayerProgress) downloaded() float64 {
+	switch l.Status {
+	case "Pulling fs layer", "Waiting":
+		return 0
+	case "Downloading":
+		if l.Total > 0 {
+			return float64(l.Current) / float64(l.Total)
+		}
+		return 0
+	}
+	// Download complete, Extracting, Pull complete, Already exists
+	return 1
+}
+
+// track applies pull progress message to state
+func (s *PullState) track(msg jsonmessage.JSONMessage) {
+	if msg.ID == "" || strings.HasPrefix(msg.Status, "Pulling from") {
+		return
+	}
+
+	if s.Layers == nil {
+		s.Layers = map[string]LayerProgress{}
+	}
+	layer := s.Layers[msg.ID]
+	layer.Status = msg.Status
+	if msg.Status == "Downloading" && msg.Progress != nil {
+		layer.Current, layer.Total = msg.Progress.Current, msg.Progress.Total
+	}
+	s.Layers[msg.ID] = layer
+
+	var sum float64
+	for _, l := range s.Layers {
+		sum += l.downloaded()
+	}
+	s.Progress = math.Round(sum/float64(len(s.Layers))*1000) / 10
+	s.UpdatedAt = time.Now()
+}
+
+var pulls = struct {
+	sync.Mutex
+	states map[string]PullState
+	// active contains running pull workers, a send wakes worker waiting for retry
+	active map[string]chan struct{}
+}{states: map[string]PullState{}, active: map[string]chan struct{}{}}
+
+// PullStatus returns state of image pull, pending if image was never pulled
+func PullStatus(ref string) PullState {
+	pulls.Lock()
+	defer pulls.Unlock()
+	state, ok := pulls.states[ref]
+	if !ok {
+		return PullState{Status: PullPending}
+	}
+	if state.Layers != nil {
+		layers := make(map[string]LayerProgress, len(state.Layers))
+		for id, layer := range state.Layers {
+			layers[id] = layer
+		}
+		state.Layers = layers
+	}
+	return state
+}
+
+func updatePull(ref string, update func(state *PullState)) {
+	pulls.Lock()
+	defer pulls.Unlock()
+	state := pulls.states[ref]
+	update(&state)
+	state.UpdatedAt = time.Now()
+	pulls.states[ref] = state
+}
+
+// SeedProfiles writes image profiles from config into database, the default
+// profile is made from registry settings unless images redefine it
+func SeedProfiles() error {
+	var profiles []config.ImageProfile
+	if err := viper.UnmarshalKey("images", &profiles); err != nil {
+		return err
+	}
+
+	hasDefault := false
+	for _, p := range profiles {
+		hasDefault = hasDefault || p.Name == models.DefaultProfile
+	}
+	if !hasDefault {
+		profiles = append(profiles, config.ImageProfile{
+			Name:     models.DefaultProfile,
+			Image:    viper.GetString("registry.image"),
+			User:     viper.GetString("registry.user"),
+			Password: viper.GetString("registry.password"),
+		})
+	}
+
+	db := models.GetDB()
+	for _, p := range profiles {
+		var profile models.ImageProfile
+		db.First(&profile, "name = ?", p.Name)
+
+		limits, err := parseLimits(p.Limits)
+		if err != nil {
+			return err
+		}
+
+		profile.Name = p.Name
+		profile.Image = p.Image
+		profile.RegistryUser = p.User
+		profile.VNCPort = p.VNCPort
+		profile.Env = p.Env
+		profile.Limits = limits
+		if profile.VNCPort == 0 {
+			profile.VNCPort = VNCPort
+		}
+		if err := profile.SetRegistryPassword(p.Password); err != nil {
+			return err
+		}
+
+		if err := db.Save(&profile).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindProfile returns image profile by name, default profile for empty name
+func FindProfile(name string) (*models.ImageProfile, bool) {
+	var profile models.ImageProfile
+	if name == "" {
+		name = models.DefaultProfile
+	}
+	if models.GetDB().First(&profile, "name = ?", name).RowsAffected == 0 {
+		return nil, false
+	}
+	return &profile, true
+}
+
+// PullProfiles pulls images of all profiles in background
+func PullProfiles(rt containers.Runtime) {
+	var profiles []models.ImageProfile
+	if err := models.GetDB().Find(&profiles).Error; err != nil {
+		log.WithError(err).Warn("Can't load image profiles")
+		return
+	}
+
+	for i := range profiles {
+		PullProfile(rt, &profiles[i])
+	}
+}
+
+// PullProfile pulls image of profile in background, failed pulls are retried
+// with exponential backoff. If image is already pulling, waiting retry is started at once.
+func PullProfile(rt containers.Runtime, profile *models.ImageProfile) {
+	ref := profile.Image
+	password, err := profile.RegistryAuth()
+	if err != nil {
+		updatePull(ref, func(state *PullState) {
+			*state = PullState{Status: PullFailed, Error: err.Error()}
+		})
+		return
+	}
+
+	pulls.Lock()
+	if wake, ok := pulls.active[ref]; ok {
+		pulls.Unlock()
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+		return
+	}
+	wake := make(chan struct{}, 1)
+	pulls.active[ref] = wake
+	pulls.states[ref] = PullState{Status: PullRunning, UpdatedAt: time.Now()}
+	pulls.Unlock()
+
+	auth := containers.Auth{Username: profile.RegistryUser, Password: password}
+	go pullWorker(rt, profile.Name, ref, auth, wake)
+}
+
+func pullWorker(rt containers.Runtime, name, ref string, auth containers.Auth, wake chan struct{}) {
+	var (
+		logger     = log.WithFields(log.Fields{"profile": name, "image": ref})
+		retries    = viper.GetInt("pull.retries")
+		backoff    = viper.GetDuration("pull.backoff")
+		maxBackoff = viper.GetDuration("pull.max_backoff")
+	)
+
+	for attempt := 1; ; attempt++ {
+		updatePull(ref, func(state *PullState) {
+			*state = PullState{Status: PullRunning, Attempt: attempt}
+		})
+
+		err := pull(context.Background(), rt, ref, auth)
+
+		// Воркер снимается с учета под той же блокировкой, что и финальное состояние,
+		// иначе повторный запуск во время завершения потеряется
+		pulls.Lock()
+		state := pulls.states[ref]
+		state.UpdatedAt = time.Now()
+		switch {
+		case err == nil:
+			state.Status, state.Progress = PullReady, 100
+		case retries != 0 && attempt > retries:
+			state.Status, state.Error = PullFailed, err.Error()
+		default:
+			next := time.Now().Add(backoff)
+			state.Status, state.Error, state.NextRetry = PullRetrying, err.Error(), &next
+		}
+		pulls.states[ref] = state
+		if state.Status != PullRetrying {
+			delete(pulls.active, ref)
+		}
+		pulls.Unlock()
+
+		switch state.Status {
+		case PullReady:
+			logger.Info("Image pulled")
+			return
+		case PullFailed:
+			logger.WithError(err).Error("Can't pull image, giving up")
+			return
+		}
+
+		logger.WithError(err).WithField("retry_in", backoff).Warn("Can't pull image")
+		select {
+		case <-time.After(backoff):
+		case <-wake:
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// pull downloads image and tracks its progress, registry errors come inside of pull stream
+func pull(ctx context.Context, rt containers.Runtime, ref string, auth containers.Auth) error {
+	out, err := rt.Pull(ctx, ref, auth)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	decoder := json.NewDecoder(out)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if msg.ErrorMessage != "" {
+			return errors.New(msg.ErrorMessage)
+		}
+		updatePull(ref, func(state *PullState) { state.track(msg) })
+	}
+}
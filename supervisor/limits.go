@@ -0,0 +1,114 @@
+package supervisor
+
+import (
+	"gradio/config"
+	"gradio/containers"
+	"gradio/models"
+	"strings"
+
+	"github.com/docker/go-units"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// LimitsFor returns container limits of class with image profile. Default limits
+// are overridden by profile limits and then by class limits, fields that are not
+// set are inherited. Class is nil for users without class.
+func LimitsFor(class *models.Class, profile *models.ImageProfile) (models.Limits, error) {
+	var defaults config.Limits
+	if err := viper.UnmarshalKey("limits", &defaults); err != nil {
+		return models.Limits{}, err
+	}
+
+	limits, err := parseLimits(defaults)
+	if err != nil {
+		return models.Limits{}, err
+	}
+
+	if profile != nil {
+		limits = mergeLimits(limits, profile.Limits)
+	}
+	if class != nil {
+		limits = mergeLimits(limits, class.Limits)
+	}
+
+	return limits, nil
+}
+
+// MigrateClassLimits moves deprecated class_limits from config into classes
+// without limits of their own. Config keys are class names, which break on
+// renaming, so limits are stored with classes.
+func MigrateClassLimits() error {
+	var classLimits map[string]config.Limits
+	if err := viper.UnmarshalKey("class_limits", &classLimits); err != nil {
+		return err
+	}
+	if len(classLimits) == 0 {
+		return nil
+	}
+
+	var classes []models.Class
+	db := models.GetDB()
+	if err := db.Find(&classes).Error; err != nil {
+		return err
+	}
+
+	for i := range classes {
+		// viper приводит ключи к нижнему регистру
+		override, ok := classLimits[strings.ToLower(classes[i].Name)]
+		if !ok || classes[i].Limits != (models.Limits{}) {
+			continue
+		}
+		limits, err := parseLimits(override)
+		if err != nil {
+			return err
+		}
+		// Updates пропускает нулевые поля, у класса они и так не заданы
+		if err := db.Model(&classes[i]).Updates(models.Class{Limits: limits}).Error; err != nil {
+			return err
+		}
+		log.WithField("class", classes[i].Name).Info("Class limits moved from config to database")
+	}
+
+	log.Warn("class_limits setting is deprecated, manage limits with /admin/classes API")
+	return nil
+}
+
+// parseLimits converts limits from config
+func parseLimits(limits config.Limits) (models.Limits, error) {
+	result := models.Limits{CPUs: limits.CPUs, Pids: limits.Pids, Disk: limits.Disk}
+	if limits.Memory != "" {
+		memory, err := units.RAMInBytes(limits.Memory)
+		if err != nil {
+			return models.Limits{}, err
+		}
+		result.Memory = memory
+	}
+	return result, nil
+}
+
+// mergeLimits overrides base limits with fields of override that are set
+func mergeLimits(base, override models.Limits) models.Limits {
+	if override.CPUs != 0 {
+		base.CPUs = override.CPUs
+	}
+	if override.Memory != 0 {
+		base.Memory = override.Memory
+	}
+	if override.Pids != 0 {
+		base.Pids = override.Pids
+	}
+	if override.Disk != "" {
+		base.Disk = override.Disk
+	}
+	return base
+}
+
+func resources(limits models.Limits) containers.Resources {
+	return containers.Resources{
+		NanoCPUs: int64(limits.CPUs * 1e9),
+		Memory:   limits.Memory,
+		Pids:     limits.Pids,
+		DiskSize: limits.Disk,
+	}
+}
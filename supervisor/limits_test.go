@@ -0,0 +1,92 @@
+package supervisor
+
+import (
+	"gradio/config"
+	"gradio/models"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestParseLimits(t *testing.T) {
+	tests := []struct {
+		limits  config.Limits
+		want    models.Limits
+		wantErr bool
+	}{
+		{config.Limits{}, models.Limits{}, false},
+		{
+			config.Limits{CPUs: 1.5, Memory: "2g", Pids: 1024, Disk: "10G"},
+			models.Limits{CPUs: 1.5, Memory: 2 << 30, Pids: 1024, Disk: "10G"},
+			false,
+		},
+		{config.Limits{Memory: "512m"}, models.Limits{Memory: 512 << 20}, false},
+		{config.Limits{Memory: "much"}, models.Limits{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseLimits(tt.limits)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseLimits(%+v) error = %v, want error %v", tt.limits, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseLimits(%+v) = %+v, want %+v", tt.limits, got, tt.want)
+		}
+	}
+}
+
+func TestMergeLimits(t *testing.T) {
+	base := models.Limits{CPUs: 1, Memory: 2 << 30, Pids: 1024, Disk: "10G"}
+
+	tests := []struct {
+		name     string
+		override models.Limits
+		want     models.Limits
+	}{
+		{"nothing set", models.Limits{}, base},
+		{"memory", models.Limits{Memory: 4 << 30}, models.Limits{CPUs: 1, Memory: 4 << 30, Pids: 1024, Disk: "10G"}},
+		{
+			"everything",
+			models.Limits{CPUs: 2, Memory: 1 << 30, Pids: 64, Disk: "1G"},
+			models.Limits{CPUs: 2, Memory: 1 << 30, Pids: 64, Disk: "1G"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := mergeLimits(base, tt.override); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLimitsFor(t *testing.T) {
+	defer viper.Set("limits", viper.Get("limits"))
+	viper.Set("limits", map[string]interface{}{"cpus": 1, "memory": "2g", "pids": 1024})
+
+	var (
+		profile = &models.ImageProfile{Limits: models.Limits{Memory: 4 << 30, Disk: "10G"}}
+		class   = &models.Class{Name: "11А", Limits: models.Limits{CPUs: 2}}
+	)
+
+	tests := []struct {
+		name    string
+		class   *models.Class
+		profile *models.ImageProfile
+		want    models.Limits
+	}{
+		{"defaults", nil, nil, models.Limits{CPUs: 1, Memory: 2 << 30, Pids: 1024}},
+		{"profile", nil, profile, models.Limits{CPUs: 1, Memory: 4 << 30, Pids: 1024, Disk: "10G"}},
+		{"class over profile", class, profile, models.Limits{CPUs: 2, Memory: 4 << 30, Pids: 1024, Disk: "10G"}},
+	}
+
+	for _, tt := range tests {
+		got, err := LimitsFor(tt.class, tt.profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gradio/containers"
+	"gradio/models"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// passwordLength is a length of generated VNC passwords
+const passwordLength = 12
+
+// ErrUnknownProfile is returned when session image profile doesn't exist
+var ErrUnknownProfile = errors.New("image profile not found")
+
+// ErrImageNotReady is returned when image of session profile is not pulled yet
+var ErrImageNotReady = errors.New("image not ready")
+
+// Options are parameters of a new session
+type Options struct {
+	// Assignment sets starter files, time limit and profile of session, may be nil
+	Assignment *models.Assignment
+	// Profile overrides image profile of assignment, default profile is used if both are not set
+	Profile *models.ImageProfile
+	// Lifetime limits session duration, the earliest of lifetime and assignment time limit is used
+	Lifetime time.Duration
+	// Prewarm marks session started in advance on behalf of student, its idle
+	// time is counted only after the first VNC connection
+	Prewarm bool
+}
+
+// profile returns image profile for the session
+func (o Options) profile() (*models.ImageProfile, error) {
+	if o.Profile != nil {
+		return o.Profile, nil
+	}
+
+	var name string
+	if o.Assignment != nil {
+		name = o.Assignment.Profile
+	}
+	if profile, ok := FindProfile(name); ok {
+		return profile, nil
+	}
+	return nil, ErrUnknownProfile
+}
+
+// Open returns the user session, starting a new container if user has none.
+// Concurrent calls for one user are serialized, so only one container is started.
+func Open(ctx context.Context, rt containers.Runtime, user *models.User, opts Options) (*models.Session, error) {
+	var (
+		db      = models.GetDB()
+		session models.Session
+	)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", user.ID).Error; err != nil {
+			return err
+		}
+
+		if tx.First(&session, "user_id = ?", user.ID).RowsAffected != 0 {
+			return nil
+		}
+
+		profile, err := opts.profile()
+		if err != nil {
+			return err
+		}
+
+		ready, err := rt.HasImage(ctx, profile.Image)
+		if err != nil {
+			return err
+		}
+		if !ready {
+			// Запускаем загрузку, если она не идет, например после исчерпания повторов
+			if status := PullStatus(profile.Image).Status; status == PullPending || status == PullFailed {
+				PullProfile(rt, profile)
+			}
+			return ErrImageNotReady
+		}
+
+		limits, err := LimitsFor(user.Class, profile)
+		if err != nil {
+			return err
+		}
+
+		password, err := models.RandomPassword(passwordLength)
+		if err != nil {
+			return err
+		}
+
+		session = models.Session{
+			UserID:    user.ID,
+			Limits:    limits,
+			Profile:   profile.Name,
+			VNCPort:   profile.VNCPort,
+			Prewarmed: opts.Prewarm,
+		}
+		if a := opts.Assignment; a != nil {
+			session.AssignmentID = &a.ID
+			if a.TimeLimit > 0 {
+				expires := time.Now().Add(time.Duration(a.TimeLimit) * time.Minute)
+				session.ExpiresAt = &expires
+			}
+		}
+		if opts.Lifetime > 0 {
+			expires := time.Now().Add(opts.Lifetime)
+			if session.ExpiresAt == nil || expires.Before(*session.ExpiresAt) {
+				session.ExpiresAt = &expires
+			}
+		}
+		if err := session.SetPassword(password); err != nil {
+			return err
+		}
+
+		if viper.GetBool("vnc.publish_ports") {
+			if session.Port, err = models.AllocatePort(); err != nil {
+				return err
+			}
+			session.VNCURL = fmt.Sprintf("vnc://vuc@%s:%d", viper.GetString("external_host"), session.Port)
+		}
+
+		spec, err := sessionSpec(ctx, rt, user, profile, &session, password)
+		if err != nil {
+			models.ReleasePort(session.Port)
+			return err
+		}
+
+		if session.ContainerID, err = runContainer(ctx, rt, spec, opts.Assignment); err != nil {
+			models.ReleasePort(session.Port)
+			return err
+		}
+		cleanup := func() {
+			removeContainer(ctx, rt, session.ContainerID)
+			models.ReleasePort(session.Port)
+		}
+
+		if err := tx.Create(&session).Error; err != nil {
+			cleanup()
+			return err
+		}
+
+		// Ссылка на noVNC страницу содержит ID сессии, известный только после создания
+		session.ConnectionURL = fmt.Sprintf("%s://%s/vnc?session=%s&token=%s",
+			viper.GetString("external_schema"), externalHost(), session.ID, session.AccessToken)
+		if err := tx.Model(&session).Update("connection_url", session.ConnectionURL).Error; err != nil {
+			cleanup()
+			return err
+		}
+		return nil
+	})
+
+	// Сессию создали в обход блокировки, отдаем существующую
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		if db.First(&session, "user_id = ?", user.ID).RowsAffected != 0 {
+			return &session, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// sessionSpec returns spec of the session container with given VNC password
+func sessionSpec(ctx context.Context, rt containers.Runtime, user *models.User, profile *models.ImageProfile, session *models.Session, password string) (containers.Spec, error) {
+	home, err := Home(ctx, rt, user)
+	if err != nil {
+		return containers.Spec{}, err
+	}
+
+	spec := containers.Spec{
+		Image:     profile.Image,
+		Env:       append([]string{"VNC_PASSWORD=" + password}, profile.Env...),
+		Labels:    Labels(user.ID),
+		Network:   viper.GetString("vnc.network"),
+		Resources: resources(session.Limits),
+		Mounts:    []containers.Mount{home},
+	}
+	if session.Port != 0 {
+		spec.Ports = map[int]int{int(session.Port): session.VNCPort}
+	}
+	return spec, nil
+}
+
+// externalHost returns host and port of gradio in links for browsers, port is
+// omitted if it's default for external schema
+func externalHost() string {
+	var (
+		host   = viper.GetString("external_host")
+		schema = viper.GetString("external_schema")
+		port   = viper.GetInt("external_port")
+	)
+	if port == 0 {
+		port = viper.GetInt("listen_port")
+	}
+	if (schema == "http" && port == 80) || (schema == "https" && port == 443) {
+		return host
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+func runContainer(ctx context.Context, rt containers.Runtime, spec containers.Spec, assignment *models.Assignment) (containerID string, err error) {
+	containerID, err = rt.Create(ctx, spec)
+	if err != nil {
+		return "", err
+	}
+
+	if err := rt.Start(ctx, containerID); err != nil {
+		rt.Remove(ctx, containerID)
+		return "", err
+	}
+
+	if assignment != nil && len(assignment.Starter) != 0 {
+		if err := copyStarter(ctx, rt, containerID, assignment); err != nil {
+			removeContainer(ctx, rt, containerID)
+			return "", err
+		}
+	}
+
+	return containerID, nil
+}
@@ -0,0 +1,128 @@
+package supervisor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"gradio/containers"
+	"gradio/models"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// StartReaper runs background closing of idle and expired sessions
+func StartReaper(rt containers.Runtime) {
+	go func() {
+		for {
+			interval := viper.GetDuration("sessions.reap_interval")
+			if interval <= 0 {
+				interval = time.Minute
+			}
+			time.Sleep(interval)
+			Reap(context.Background(), rt)
+		}
+	}()
+}
+
+// Reap closes sessions that have no VNC clients longer than idle timeout,
+// live longer than max lifetime or outlive assignment time limit. Idle time of
+// prewarmed sessions is counted from the first VNC connection.
+func Reap(ctx context.Context, rt containers.Runtime) {
+	var (
+		db          = models.GetDB()
+		sessions    []models.Session
+		idleTimeout = viper.GetDuration("sessions.idle_timeout")
+		maxLifetime = viper.GetDuration("sessions.max_lifetime")
+	)
+
+	if err := db.Find(&sessions).Error; err != nil {
+		log.WithError(err).Warn("Reaper can't load sessions")
+		return
+	}
+
+	now := time.Now()
+	for i := range sessions {
+		var (
+			session = &sessions[i]
+			reason  string
+		)
+
+		switch {
+		case maxLifetime > 0 && now.Sub(session.CreatedAt) > maxLifetime:
+			reason = models.CloseExpired
+		case session.ExpiresAt != nil && now.After(*session.ExpiresAt):
+			reason = models.CloseExpired
+		case idleTimeout > 0:
+			connected, err := vncConnected(ctx, rt, session)
+			if err != nil {
+				// Неизвестно, есть ли клиенты, живую сессию закрывать нельзя
+				log.WithError(err).WithField("session", session.ID).Warn("Can't check session VNC clients, skipping")
+				continue
+			}
+			if connected {
+				if err := session.Touch(); err != nil {
+					log.WithError(err).WithField("session", session.ID).Warn("Can't update session activity")
+				}
+				continue
+			}
+			// Заранее запущенная сессия ждет студента, ограничена только временем жизни
+			if session.Prewarmed && session.LastSeenAt == nil {
+				continue
+			}
+			if now.Sub(session.LastSeen()) > idleTimeout {
+				reason = models.CloseIdle
+			}
+		}
+
+		if reason == "" {
+			continue
+		}
+
+		logger := log.WithFields(log.Fields{
+			"session":   session.ID,
+			"user":      session.UserID,
+			"reason":    reason,
+			"last_seen": session.LastSeen().Format(time.RFC3339),
+		})
+		if _, err := Close(ctx, rt, session, reason); err != nil {
+			logger.WithError(err).Warn("Can't reap session")
+			continue
+		}
+		logger.Info("Session reaped")
+	}
+}
+
+// vncConnected checks for established TCP connections to VNC server inside
+// container, error means that connections are unknown
+func vncConnected(ctx context.Context, rt containers.Runtime, session *models.Session) (bool, error) {
+	out, err := rt.Exec(ctx, session.ContainerID, []string{"sh", "-c", "cat /proc/net/tcp*"})
+	if err != nil {
+		return false, err
+	}
+	return countEstablished(out, session.VNCPort) > 0, nil
+}
+
+// countEstablished counts established connections to local port in /proc/net/tcp format
+func countEstablished(procNetTCP []byte, port int) (count int) {
+	var (
+		scanner = bufio.NewScanner(bytes.NewReader(procNetTCP))
+		suffix  = fmt.Sprintf(":%04X", port)
+	)
+
+	for scanner.Scan() {
+		// sl local_address rem_address st ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		// 01 is TCP_ESTABLISHED
+		if strings.HasSuffix(fields[1], suffix) && fields[3] == "01" {
+			count++
+		}
+	}
+	return
+}
@@ -0,0 +1,36 @@
+package supervisor
+
+import "testing"
+
+func TestCountEstablished(t *testing.T) {
+	const procNetTCP = "  sl  local_address rem_address   st tx_queue rx_queue\n" +
+		// 0.0.0.0:5900 в состоянии LISTEN
+		"   0: 00000000:170C 00000000:0000 0A 00000000:00000000 00:00000000 00000000 0 0 1 1\n" +
+		"   1: 0100007F:170C 0100007F:D2A4 01 00000000:00000000 00:00000000 00000000 0 0 2 1\n" +
+		"   2: 0100007F:170C 0100007F:D2A6 01 00000000:00000000 00:00000000 00000000 0 0 3 1\n" +
+		// Соединение в TIME_WAIT
+		"   3: 0100007F:170C 0100007F:D2A8 06 00000000:00000000 00:00000000 00000000 0 0 4 1\n" +
+		// Исходящее соединение на удаленный порт 5900
+		"   4: 0100007F:D2AA 0100007F:170C 01 00000000:00000000 00:00000000 00000000 0 0 5 1\n" +
+		// tcp6, порт 5901
+		"   0: 00000000000000000000000001000000:170D 00000000000000000000000001000000:D2AC 01 00000000:00000000 00:00000000 00000000 0 0 6 1\n"
+
+	tests := []struct {
+		port int
+		want int
+	}{
+		{5900, 2},
+		{5901, 1},
+		{5902, 0},
+	}
+
+	for _, tt := range tests {
+		if got := countEstablished([]byte(procNetTCP), tt.port); got != tt.want {
+			t.Errorf("countEstablished(%d) = %d, want %d", tt.port, got, tt.want)
+		}
+	}
+
+	if got := countEstablished(nil, 5900); got != 0 {
+		t.Errorf("countEstablished of empty output = %d, want 0", got)
+	}
+}
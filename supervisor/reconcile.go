@@ -0,0 +1,113 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"gradio/containers"
+	"gradio/models"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// orphanGrace protects just created containers whose session is not saved yet
+const orphanGrace = 5 * time.Minute
+
+// StartReconciler reconciles sessions with containers right now and then periodically
+func StartReconciler(rt containers.Runtime) {
+	Reconcile(context.Background(), rt)
+	go func() {
+		for {
+			interval := viper.GetDuration("sessions.reconcile_interval")
+			if interval <= 0 {
+				interval = 10 * time.Minute
+			}
+			time.Sleep(interval)
+			Reconcile(context.Background(), rt)
+		}
+	}()
+}
+
+// Reconcile removes gradio containers without session, closes sessions without
+// container and restarts (or closes) sessions with stopped containers
+func Reconcile(ctx context.Context, rt containers.Runtime) {
+	var (
+		db       = models.GetDB()
+		sessions []models.Session
+	)
+
+	list, err := rt.List(ctx, map[string]string{LabelManaged: "true"})
+	if err != nil {
+		log.WithError(err).Warn("Reconciler can't list containers")
+		return
+	}
+
+	if err := db.Find(&sessions).Error; err != nil {
+		log.WithError(err).Warn("Reconciler can't load sessions")
+		return
+	}
+
+	running := make(map[string]containers.Info, len(list))
+	for _, info := range list {
+		running[info.ID] = info
+	}
+
+	for i := range sessions {
+		session := &sessions[i]
+		logger := log.WithFields(log.Fields{"session": session.ID, "container": session.ContainerID})
+
+		info, ok := running[session.ContainerID]
+		delete(running, session.ContainerID)
+		if !ok {
+			// Контейнеры, созданные до появления меток
+			if info, err = rt.Inspect(ctx, session.ContainerID); errors.Is(err, containers.ErrNotFound) {
+				closeDead(ctx, rt, session, logger)
+				continue
+			} else if err != nil {
+				logger.WithError(err).Warn("Reconciler can't inspect session container")
+				continue
+			}
+		}
+
+		if info.Running {
+			continue
+		}
+
+		if viper.GetBool("sessions.restart_stopped") {
+			if err = Restart(ctx, rt, session); err == nil {
+				logger.WithField("new_container", session.ContainerID).Info("Session container restarted")
+				continue
+			}
+			logger.WithError(err).Warn("Can't restart session container")
+		}
+		closeDead(ctx, rt, session, logger)
+	}
+
+	for id, info := range running {
+		if time.Since(info.Created) < orphanGrace {
+			continue
+		}
+
+		logger := log.WithFields(log.Fields{"container": id, "user": info.Labels[LabelUser]})
+		if err := removeContainer(ctx, rt, id); err != nil && !errors.Is(err, containers.ErrNotFound) {
+			logger.WithError(err).Warn("Can't remove orphan container")
+			continue
+		}
+		logger.Info("Orphan container removed")
+	}
+
+	if released, err := models.ReleaseStalePorts(time.Now().Add(-orphanGrace)); err != nil {
+		log.WithError(err).Warn("Can't release stale ports")
+	} else if released != 0 {
+		log.WithField("count", released).Info("Stale ports released")
+	}
+}
+
+func closeDead(ctx context.Context, rt containers.Runtime, session *models.Session, logger *log.Entry) {
+	if _, err := Close(ctx, rt, session, models.CloseDead); err != nil {
+		logger.WithError(err).Warn("Can't close dead session")
+		return
+	}
+	logger.Info("Dead session closed")
+}
@@ -0,0 +1,109 @@
+package supervisor
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"gradio/containers"
+	"gradio/models"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// copyStarter extracts assignment starter files into its directory of student
+// home. Directory that already exists is kept as is, so student work is not
+// overwritten on the next session.
+func copyStarter(ctx context.Context, rt containers.Runtime, containerID string, assignment *models.Assignment) error {
+	var (
+		home = viper.GetString("workspace.path")
+		dir  = StarterDir(assignment)
+	)
+
+	out, _, err := rt.CopyFrom(ctx, containerID, path.Join(home, dir))
+	if err == nil {
+		out.Close()
+		return nil
+	} else if !errors.Is(err, containers.ErrNotFound) {
+		return err
+	}
+
+	archive, err := zipToTar(assignment.Starter, dir)
+	if err != nil {
+		return err
+	}
+
+	return rt.CopyTo(ctx, containerID, home, archive)
+}
+
+// StarterDir returns name of the assignment directory in student home
+func StarterDir(assignment *models.Assignment) string {
+	dir := strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == 0 {
+			return '_'
+		}
+		return r
+	}, assignment.Title))
+	if dir == "" || dir == "." || dir == ".." {
+		return assignment.ID
+	}
+	return dir
+}
+
+// zipToTar repacks zip archive into tar under the dir prefix
+func zipToTar(data []byte, dir string) (io.Reader, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		buf bytes.Buffer
+		tw  = tar.NewWriter(&buf)
+		uid = viper.GetInt("workspace.uid")
+		gid = viper.GetInt("workspace.gid")
+	)
+
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: dir + "/", Mode: 0755, Uid: uid, Gid: gid}); err != nil {
+		return nil, err
+	}
+
+	for _, f := range zr.File {
+		// Пути из архива не должны выходить за каталог задания
+		name := path.Join(dir, path.Clean("/"+f.Name))
+		if f.FileInfo().IsDir() {
+			if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name + "/", Mode: 0755, Uid: uid, Gid: gid, ModTime: f.Modified}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(f.UncompressedSize64),
+			Uid:      uid,
+			Gid:      gid,
+			ModTime:  f.Modified,
+		}); err != nil {
+			return nil, err
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(tw, r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &buf, tw.Close()
+}
@@ -0,0 +1,79 @@
+package supervisor
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestZipToTar(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := []struct {
+		name, content string
+	}{
+		{"README.md", "lab"},
+		{"grc/", ""},
+		{"grc/fm.grc", "<flowgraph/>"},
+		{"../../etc/passwd", "root"},
+	}
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, f.content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Set("workspace.uid", 1000)
+	viper.Set("workspace.gid", 1000)
+	archive, err := zipToTar(buf.Bytes(), "FM receiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"FM receiver/":           "",
+		"FM receiver/README.md":  "lab",
+		"FM receiver/grc/":       "",
+		"FM receiver/grc/fm.grc": "<flowgraph/>",
+		"FM receiver/etc/passwd": "root",
+	}
+	tr := tar.NewReader(archive)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		content, ok := want[header.Name]
+		if !ok {
+			t.Errorf("unexpected entry %q", header.Name)
+			continue
+		}
+		delete(want, header.Name)
+		if header.Uid != 1000 || header.Gid != 1000 {
+			t.Errorf("%s is owned by %d:%d, want 1000:1000", header.Name, header.Uid, header.Gid)
+		}
+		if got, _ := io.ReadAll(tr); string(got) != content {
+			t.Errorf("%s content = %q, want %q", header.Name, got, content)
+		}
+	}
+	for name := range want {
+		t.Errorf("entry %q is missing", name)
+	}
+
+	if _, err := zipToTar([]byte("not a zip"), "lab"); err == nil {
+		t.Error("broken zip is repacked without error")
+	}
+}
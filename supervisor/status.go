@@ -0,0 +1,58 @@
+package supervisor
+
+import (
+	"context"
+	"gradio/containers"
+	"gradio/models"
+)
+
+// Состояния сессий пользователей
+const (
+	StatusNone    = "none"
+	StatusOnline  = "online"
+	StatusOffline = "offline"
+)
+
+// RunningContainers returns IDs of running session containers
+func RunningContainers(ctx context.Context, rt containers.Runtime) (map[string]bool, error) {
+	list, err := rt.List(ctx, map[string]string{LabelManaged: "true"})
+	if err != nil {
+		return nil, err
+	}
+
+	running := make(map[string]bool, len(list))
+	for _, info := range list {
+		if info.Running {
+			running[info.ID] = true
+		}
+	}
+	return running, nil
+}
+
+// SessionStatus returns session status of user: none if user has no session,
+// online if session container is running, offline otherwise.
+// Session of user must be preloaded.
+func SessionStatus(user *models.User, running map[string]bool) string {
+	switch {
+	case user.Session == nil:
+		return StatusNone
+	case running[user.Session.ContainerID]:
+		return StatusOnline
+	}
+	return StatusOffline
+}
+
+// SessionStatuses returns session status of every user by user ID.
+// Sessions of users must be preloaded.
+func SessionStatuses(ctx context.Context, rt containers.Runtime, users []models.User) (map[string]string, error) {
+	running, err := RunningContainers(ctx, rt)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[string]string, len(users))
+	for i := range users {
+		statuses[users[i].ID] = SessionStatus(&users[i], running)
+	}
+	return statuses, nil
+}
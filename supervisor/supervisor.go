@@ -0,0 +1,113 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"gradio/containers"
+	"gradio/models"
+)
+
+// VNCPort is a VNC server port inside lab container
+const VNCPort = 5900
+
+// Метки контейнеров, созданных gradio
+const (
+	LabelManaged = "gradio.managed"
+	LabelUser    = "gradio.user"
+)
+
+// Labels returns labels of the session container of user
+func Labels(userID string) map[string]string {
+	return map[string]string{LabelManaged: "true", LabelUser: userID}
+}
+
+// Close removes the session container and soft-deletes the session with the
+// given reason. reclaimed is false if the container was already gone.
+func Close(ctx context.Context, rt containers.Runtime, session *models.Session, reason string) (reclaimed bool, err error) {
+	db := models.GetDB()
+
+	if err = removeContainer(ctx, rt, session.ContainerID); err != nil && !errors.Is(err, containers.ErrNotFound) {
+		return false, err
+	}
+	reclaimed = err == nil
+
+	port := session.Port
+	if err = db.Model(session).Updates(map[string]interface{}{"port": 0, "closed_reason": reason}).Error; err != nil {
+		return reclaimed, err
+	}
+
+	if err = db.Delete(session).Error; err != nil {
+		return reclaimed, err
+	}
+
+	return reclaimed, models.ReleasePort(port)
+}
+
+// removeContainer stops and removes the session container
+func removeContainer(ctx context.Context, rt containers.Runtime, containerID string) error {
+	if err := rt.Stop(ctx, containerID); err != nil {
+		return err
+	}
+	return rt.Remove(ctx, containerID)
+}
+
+// Restart replaces stopped session container with a new one. Container is
+// recreated rather than started, because VNC_PASSWORD in its env is outdated
+// after RotatePassword and the image applies it on every start.
+func Restart(ctx context.Context, rt containers.Runtime, session *models.Session) error {
+	var user models.User
+	if err := user.Get(session.UserID); err != nil {
+		return err
+	}
+
+	profile, ok := FindProfile(session.Profile)
+	if !ok {
+		return ErrUnknownProfile
+	}
+
+	password, err := session.Password()
+	if err != nil {
+		return err
+	}
+
+	spec, err := sessionSpec(ctx, rt, &user, profile, session, password)
+	if err != nil {
+		return err
+	}
+
+	// Опубликованный порт занят старым контейнером, пока он не удален
+	if err := rt.Remove(ctx, session.ContainerID); err != nil && !errors.Is(err, containers.ErrNotFound) {
+		return err
+	}
+
+	// Стартовые файлы уже лежат в домашней папке
+	containerID, err := runContainer(ctx, rt, spec, nil)
+	if err != nil {
+		return err
+	}
+
+	session.ContainerID = containerID
+	return models.GetDB().Model(session).Update("container_id", containerID).Error
+}
+
+// RotatePassword sets new VNC password inside the running session container
+// and returns it. Connected VNC clients are dropped. A stopped container gets
+// the password on Restart.
+func RotatePassword(ctx context.Context, rt containers.Runtime, session *models.Session) (string, error) {
+	password, err := models.RandomPassword(passwordLength)
+	if err != nil {
+		return "", err
+	}
+
+	// Образ ubuntu-desktop-lxde-vnc хранит пароль в /.password2 и запускает x11vnc через supervisord
+	if _, err := rt.Exec(ctx, session.ContainerID, []string{"sh", "-c",
+		`x11vnc -storepasswd "$0" /.password2 && supervisorctl restart x11vnc`, password}); err != nil {
+		return "", err
+	}
+
+	if err := session.SetPassword(password); err != nil {
+		return "", err
+	}
+
+	return password, models.GetDB().Model(session).Update("vnc_password", session.VNCPassword).Error
+}
@@ -0,0 +1,264 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gradio/containers"
+	"gradio/internal/testdb"
+	"gradio/models"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// testImage is an image of the default profile in tests
+const testImage = "gradio/desktop:test"
+
+// setup connects test database and returns fake runtime with pulled image of
+// the default profile and a student without session
+func setup(t *testing.T) (*containers.Fake, *models.User) {
+	t.Helper()
+	testdb.Open(t, "supervisor")
+
+	viper.Set("vnc.secret", "test")
+	viper.Set("vnc.publish_ports", false)
+	viper.Set("registry.image", testImage)
+	viper.Set("external_schema", "http")
+	viper.Set("external_host", "lab.example.com")
+	viper.Set("external_port", 0)
+	viper.Set("listen_port", 3000)
+	viper.Set("sessions.idle_timeout", 30*time.Minute)
+	viper.Set("sessions.max_lifetime", 4*time.Hour)
+
+	if err := SeedProfiles(); err != nil {
+		t.Fatal(err)
+	}
+
+	user := models.User{Surname: "Ivanov", Login: "ivanov", Rights: models.RightsStudent}
+	if err := user.GenHash(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.GetDB().Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	rt := containers.NewFake()
+	rt.Images[testImage] = true
+	return rt, &user
+}
+
+func TestOpen(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		rt, user = setup(t)
+	)
+
+	session, err := Open(ctx, rt, user, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := rt.Inspect(ctx, session.ContainerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Running || info.Image != testImage || info.Labels[LabelUser] != user.ID {
+		t.Errorf("unexpected container %+v", info)
+	}
+	if !rt.Volumes["gradio-home-"+user.ID] {
+		t.Error("home volume is not created")
+	}
+	if session.Profile != models.DefaultProfile {
+		t.Errorf("profile = %q, want %q", session.Profile, models.DefaultProfile)
+	}
+	if password, err := session.Password(); err != nil || len(password) != passwordLength {
+		t.Errorf("password = %q, %v", password, err)
+	}
+	want := fmt.Sprintf("http://lab.example.com:3000/vnc?session=%s&token=%s", session.ID, session.AccessToken)
+	if session.ConnectionURL != want {
+		t.Errorf("connection url = %q, want %q", session.ConnectionURL, want)
+	}
+
+	again, err := Open(ctx, rt, user, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != session.ID {
+		t.Errorf("second open started session %s, want existing %s", again.ID, session.ID)
+	}
+	if list, _ := rt.List(ctx, Labels(user.ID)); len(list) != 1 {
+		t.Errorf("user has %d containers, want 1", len(list))
+	}
+}
+
+func TestOpenImageNotReady(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		rt, user = setup(t)
+	)
+	delete(rt.Images, testImage)
+	pulls.Lock()
+	delete(pulls.states, testImage)
+	pulls.Unlock()
+
+	if _, err := Open(ctx, rt, user, Options{}); !errors.Is(err, ErrImageNotReady) {
+		t.Fatalf("open error = %v, want %v", err, ErrImageNotReady)
+	}
+	if list, _ := rt.List(ctx, Labels(user.ID)); len(list) != 0 {
+		t.Errorf("user has %d containers, want 0", len(list))
+	}
+
+	// Open запускает загрузку образа в фоне
+	for deadline := time.Now().Add(5 * time.Second); PullStatus(testImage).Status != PullReady; {
+		if time.Now().After(deadline) {
+			t.Fatalf("image is not pulled: %+v", PullStatus(testImage))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := Open(ctx, rt, user, Options{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		db       = models.GetDB()
+		rt, user = setup(t)
+	)
+
+	session, err := Open(ctx, rt, user, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reclaimed, err := Close(ctx, rt, session, models.CloseByAdmin)
+	if err != nil || !reclaimed {
+		t.Fatalf("close = %v, %v, want reclaimed container", reclaimed, err)
+	}
+	if _, err := rt.Inspect(ctx, session.ContainerID); !errors.Is(err, containers.ErrNotFound) {
+		t.Errorf("container is not removed: %v", err)
+	}
+
+	var closed models.Session
+	db.Unscoped().First(&closed, "id = ?", session.ID)
+	if !closed.DeletedAt.Valid || closed.ClosedReason != models.CloseByAdmin {
+		t.Errorf("session is not closed by admin: deleted %v, reason %q", closed.DeletedAt.Valid, closed.ClosedReason)
+	}
+
+	// Контейнер уже удален, сессия все равно закрывается
+	session, err = Open(ctx, rt, user, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Remove(ctx, session.ContainerID); err != nil {
+		t.Fatal(err)
+	}
+	reclaimed, err = Close(ctx, rt, session, models.CloseByUser)
+	if err != nil || reclaimed {
+		t.Fatalf("close = %v, %v, want not reclaimed container", reclaimed, err)
+	}
+	if db.First(&models.Session{}, "id = ?", session.ID).RowsAffected != 0 {
+		t.Error("session without container is not closed")
+	}
+}
+
+func TestReap(t *testing.T) {
+	// Установленное соединение с VNC портом 5900 (0x170C) в формате /proc/net/tcp
+	const connected = "  sl  local_address rem_address   st\n" +
+		"   0: 0100007F:170C 0100007F:D2A4 01 00000000:00000000 00:00000000 00000000 0 0 1 1\n"
+
+	var (
+		hourAgo   = time.Now().Add(-time.Hour)
+		minuteAgo = time.Now().Add(-time.Minute)
+	)
+
+	tests := []struct {
+		name      string
+		createdAt time.Time
+		lastSeen  *time.Time
+		expiresAt *time.Time
+		prewarmed bool
+		exec      func(id string, cmd []string) ([]byte, error)
+		reason    string // пусто, если сессия должна остаться
+	}{
+		{name: "idle", createdAt: hourAgo, lastSeen: &hourAgo, reason: models.CloseIdle},
+		{name: "never connected", createdAt: hourAgo, reason: models.CloseIdle},
+		{name: "recently seen", createdAt: hourAgo, lastSeen: &minuteAgo},
+		{
+			name: "connected", createdAt: hourAgo, lastSeen: &hourAgo,
+			exec: func(string, []string) ([]byte, error) { return []byte(connected), nil },
+		},
+		{
+			name: "connections unknown", createdAt: hourAgo, lastSeen: &hourAgo,
+			exec: func(string, []string) ([]byte, error) { return nil, errors.New("sh not found") },
+		},
+		{name: "expired", createdAt: time.Now(), expiresAt: &minuteAgo, reason: models.CloseExpired},
+		{name: "max lifetime", createdAt: time.Now().Add(-5 * time.Hour), lastSeen: &minuteAgo, reason: models.CloseExpired},
+		{name: "prewarmed waiting", createdAt: hourAgo, prewarmed: true},
+		{name: "prewarmed idle", createdAt: hourAgo, lastSeen: &hourAgo, prewarmed: true, reason: models.CloseIdle},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				ctx      = context.Background()
+				db       = models.GetDB()
+				rt, user = setup(t)
+			)
+			rt.ExecHandler = tt.exec
+
+			session, err := Open(ctx, rt, user, Options{Prewarm: tt.prewarmed})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Model(session).UpdateColumns(map[string]interface{}{
+				"created_at":   tt.createdAt,
+				"last_seen_at": tt.lastSeen,
+				"expires_at":   tt.expiresAt,
+			}).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			Reap(ctx, rt)
+
+			var reaped models.Session
+			db.Unscoped().First(&reaped, "id = ?", session.ID)
+			if reaped.DeletedAt.Valid != (tt.reason != "") || reaped.ClosedReason != tt.reason {
+				t.Errorf("closed %v with reason %q, want reason %q", reaped.DeletedAt.Valid, reaped.ClosedReason, tt.reason)
+			}
+			if _, err := rt.Inspect(ctx, session.ContainerID); (err == nil) != (tt.reason == "") {
+				t.Errorf("container exists %v, want %v", err == nil, tt.reason == "")
+			}
+		})
+	}
+}
+
+func TestExternalHost(t *testing.T) {
+	tests := []struct {
+		schema       string
+		externalPort int
+		listenPort   int
+		want         string
+	}{
+		{"http", 0, 3000, "lab.example.com:3000"},
+		{"http", 0, 80, "lab.example.com"},
+		{"https", 443, 3000, "lab.example.com"},
+		{"https", 8443, 3000, "lab.example.com:8443"},
+		{"http", 443, 3000, "lab.example.com:443"},
+	}
+
+	viper.Set("external_host", "lab.example.com")
+	for _, tt := range tests {
+		viper.Set("external_schema", tt.schema)
+		viper.Set("external_port", tt.externalPort)
+		viper.Set("listen_port", tt.listenPort)
+		if got := externalHost(); got != tt.want {
+			t.Errorf("%s with external port %d and listen port %d: got %q, want %q",
+				tt.schema, tt.externalPort, tt.listenPort, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+#!/bin/sh
+# Скачивает клиентскую библиотеку noVNC, которая встраивается в gradio:
+# go generate ./web
+set -e
+
+VERSION=1.3.0
+# sha256 архива тега v$VERSION, скачанный архив с другой суммой не распаковывается
+SHA256=
+
+cd "$(dirname "$0")"
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+curl -fsSL -o "$tmp/novnc.tar.gz" "https://github.com/novnc/noVNC/archive/refs/tags/v$VERSION.tar.gz"
+sum=$(sha256sum "$tmp/novnc.tar.gz" | cut -d ' ' -f 1)
+if [ -z "$SHA256" ]; then
+	echo "noVNC $VERSION checksum is not pinned, check the archive and set SHA256=$sum" >&2
+	exit 1
+fi
+if [ "$sum" != "$SHA256" ]; then
+	echo "noVNC $VERSION checksum mismatch: got $sum, want $SHA256" >&2
+	exit 1
+fi
+tar -xzf "$tmp/novnc.tar.gz" -C "$tmp"
+src="$tmp/noVNC-$VERSION"
+
+rm -rf novnc/core novnc/vendor novnc/LICENSE.txt
+mkdir -p novnc/vendor
+cp -r "$src/core" novnc/
+# core/inflator.js и core/deflator.js импортируют pako
+cp -r "$src/vendor/pako" novnc/vendor/
+cp "$src/LICENSE.txt" novnc/
+echo "noVNC $VERSION" > novnc/VERSION
@@ -0,0 +1,27 @@
+package web
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+)
+
+// VNCPage is a noVNC client page connecting to the session websocket
+//
+//go:embed vnc.html
+var VNCPage []byte
+
+// NoVNC contains noVNC client library in novnc directory, served from /novnc
+//
+//go:generate sh fetch-novnc.sh
+//go:embed novnc
+var NoVNC embed.FS
+
+// CheckNoVNC returns error if noVNC library was not vendored before build,
+// the /vnc page doesn't work without it
+func CheckNoVNC() error {
+	if _, err := fs.Stat(NoVNC, "novnc/core/rfb.js"); err != nil {
+		return errors.New("novnc/core/rfb.js is not embedded, run go generate ./web and rebuild")
+	}
+	return nil
+}